@@ -57,8 +57,13 @@ func (s *AccountService) Get(domain, username string) (*Account, error) {
 	return &account, err
 }
 
-// Create creates a new account with the given username in the given domain.
+// Create creates a new account with the given username in the given domain. The password
+// is checked against the password policy of the client, if any.
 func (s *AccountService) Create(domain, username, password string) error {
+	if err := s.client.checkPassword(domain, username, password); err != nil {
+		return err
+	}
+
 	ur := &AccountUpdateRequest{
 		Username:        username,
 		Password:        password,
@@ -75,8 +80,13 @@ func (s *AccountService) Create(domain, username, password string) error {
 	return err
 }
 
-// Update updates the specified account.
+// Update updates the specified account. A new password is checked against the password
+// policy of the client, if any.
 func (s *AccountService) Update(domain, username string, updateRequest *AccountUpdateRequest) error {
+	if err := s.client.checkUpdatePassword(domain, username, updateRequest); err != nil {
+		return err
+	}
+
 	req, err := s.client.NewRequest(http.MethodPut, fmt.Sprintf("%v/%v", getAccountsURL(domain), username), updateRequest)
 	if err != nil {
 		return err
@@ -0,0 +1,298 @@
+package goprsc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// DefaultAliasMaxDepth is the default maximum number of alias expansions performed while
+// resolving an address.
+const DefaultAliasMaxDepth = 100
+
+// AliasGraph is a directed graph of alias addresses and their destinations across domains.
+// Only enabled aliases are part of the graph.
+type AliasGraph struct {
+	// MaxDepth is the maximum number of nested alias expansions allowed while resolving an
+	// address (defaults to DefaultAliasMaxDepth).
+	MaxDepth int
+
+	edges    map[string][]string
+	accounts map[string]bool
+	domains  map[string]bool
+}
+
+// AliasResolution is the result of expanding an address through the alias graph.
+type AliasResolution struct {
+	Address string
+
+	// Mailboxes are the local accounts which receive mail for the address.
+	Mailboxes []string
+
+	// External are the destinations outside the local domains.
+	External []string
+
+	// Unresolved are destinations in local domains which are neither accounts nor aliases.
+	Unresolved []string
+}
+
+// AliasLoopError is returned when an address can't be resolved because of an alias loop.
+type AliasLoopError struct {
+	// Path is the chain of addresses forming the loop, starting and ending with the same address.
+	Path []string
+}
+
+func (e *AliasLoopError) Error() string {
+	return fmt.Sprintf("alias loop: %s", strings.Join(e.Path, " -> "))
+}
+
+// AliasDepthError is returned when resolving an address exceeds the maximum alias depth.
+type AliasDepthError struct {
+	Address  string
+	MaxDepth int
+}
+
+func (e *AliasDepthError) Error() string {
+	return fmt.Sprintf("alias %s exceeds maximum depth of %d", e.Address, e.MaxDepth)
+}
+
+// NewAliasGraph returns an empty alias graph.
+func NewAliasGraph() *AliasGraph {
+	return &AliasGraph{
+		MaxDepth: DefaultAliasMaxDepth,
+		edges:    make(map[string][]string),
+		accounts: make(map[string]bool),
+		domains:  make(map[string]bool),
+	}
+}
+
+// Graph builds the alias graph of all domains on the server.
+func (s *AliasService) Graph() (*AliasGraph, error) {
+	domains, err := s.client.Domains.List()
+	if err != nil {
+		return nil, err
+	}
+
+	g := NewAliasGraph()
+	for _, d := range domains {
+		g.AddDomain(d.Name)
+
+		accounts, err := s.client.Accounts.List(d.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range accounts {
+			g.AddAccount(d.Name, a)
+		}
+
+		aliases, err := s.List(d.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range aliases {
+			g.AddAlias(d.Name, a)
+		}
+	}
+	return g, nil
+}
+
+// AddDomain registers a local domain.
+func (g *AliasGraph) AddDomain(domain string) {
+	g.domains[strings.ToLower(domain)] = true
+}
+
+// AddAccount registers the mailbox of an account in the given domain.
+func (g *AliasGraph) AddAccount(domain string, a Account) {
+	g.AddDomain(domain)
+	g.accounts[normalizeAddress(a.Username+"@"+domain)] = true
+}
+
+// AddAlias adds an alias in the given domain to the graph. Disabled aliases are ignored.
+func (g *AliasGraph) AddAlias(domain string, a Alias) {
+	if !a.Enabled {
+		return
+	}
+	g.AddDomain(domain)
+	from := normalizeAddress(a.Name + "@" + domain)
+	g.edges[from] = append(g.edges[from], normalizeAddress(a.Email))
+}
+
+// Resolve expands address to the final set of mailboxes and external destinations.
+// An *AliasLoopError or *AliasDepthError is returned when the address can't be resolved.
+func (g *AliasGraph) Resolve(address string) (*AliasResolution, error) {
+	r := &AliasResolution{Address: normalizeAddress(address)}
+	seen, done := make(map[string]bool), make(map[string]bool)
+	if err := g.resolve(r, r.Address, []string{}, seen, done); err != nil {
+		return nil, err
+	}
+	sort.Strings(r.Mailboxes)
+	sort.Strings(r.External)
+	sort.Strings(r.Unresolved)
+	return r, nil
+}
+
+// resolve adds the destinations of address to r. Loops are detected on the current path.
+// Leaf addresses are recorded in seen and aliases whose targets have all been processed in
+// done, so that each address is expanded only once even if it is reached on several paths.
+func (g *AliasGraph) resolve(r *AliasResolution, address string, path []string, seen, done map[string]bool) error {
+	if done[address] {
+		return nil
+	}
+	for i, p := range path {
+		if p == address {
+			return &AliasLoopError{Path: append(append([]string{}, path[i:]...), address)}
+		}
+	}
+	maxDepth := g.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultAliasMaxDepth
+	}
+	if len(path) > maxDepth {
+		return &AliasDepthError{Address: r.Address, MaxDepth: maxDepth}
+	}
+
+	targets, ok := g.edges[address]
+	if !ok {
+		if seen[address] {
+			return nil
+		}
+		seen[address] = true
+		_, domain := splitAddress(address)
+		switch {
+		case g.accounts[address]:
+			r.Mailboxes = append(r.Mailboxes, address)
+		case g.domains[domain]:
+			r.Unresolved = append(r.Unresolved, address)
+		default:
+			r.External = append(r.External, address)
+		}
+		return nil
+	}
+
+	path = append(path, address)
+	for _, t := range targets {
+		// An alias pointing to itself delivers to the mailbox with the same address.
+		if t == address {
+			if !seen[t] && g.accounts[t] {
+				seen[t] = true
+				r.Mailboxes = append(r.Mailboxes, t)
+			}
+			continue
+		}
+		if err := g.resolve(r, t, path, seen, done); err != nil {
+			return err
+		}
+	}
+	done[address] = true
+	return nil
+}
+
+// Loops returns the groups of addresses which form alias loops. Each group is sorted.
+func (g *AliasGraph) Loops() [][]string {
+	// Tarjan's strongly connected components algorithm.
+	var (
+		index   = make(map[string]int)
+		lowlink = make(map[string]int)
+		onStack = make(map[string]bool)
+		stack   []string
+		loops   [][]string
+		visit   func(v string)
+	)
+	visit = func(v string) {
+		index[v] = len(index)
+		lowlink[v] = index[v]
+		stack = append(stack, v)
+		onStack[v] = true
+
+		for _, w := range g.edges[v] {
+			if w == v {
+				continue
+			}
+			if _, ok := index[w]; !ok {
+				visit(w)
+				if lowlink[w] < lowlink[v] {
+					lowlink[v] = lowlink[w]
+				}
+			} else if onStack[w] && index[w] < lowlink[v] {
+				lowlink[v] = index[w]
+			}
+		}
+
+		if lowlink[v] == index[v] {
+			var scc []string
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				scc = append(scc, w)
+				if w == v {
+					break
+				}
+			}
+			if len(scc) > 1 {
+				sort.Strings(scc)
+				loops = append(loops, scc)
+			}
+		}
+	}
+
+	for _, v := range g.addresses() {
+		if _, ok := index[v]; !ok {
+			visit(v)
+		}
+	}
+	return loops
+}
+
+// WriteDOT writes the graph in Graphviz DOT format. Mailboxes are drawn as boxes and
+// external destinations with dashed outlines.
+func (g *AliasGraph) WriteDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph aliases {")
+	for _, v := range g.addresses() {
+		_, domain := splitAddress(v)
+		switch {
+		case g.accounts[v]:
+			fmt.Fprintf(bw, "\t%q [shape=box];\n", v)
+		case g.edges[v] == nil && !g.domains[domain]:
+			fmt.Fprintf(bw, "\t%q [style=dashed];\n", v)
+		default:
+			fmt.Fprintf(bw, "\t%q;\n", v)
+		}
+	}
+	for _, v := range g.addresses() {
+		targets := append([]string{}, g.edges[v]...)
+		sort.Strings(targets)
+		for _, t := range targets {
+			fmt.Fprintf(bw, "\t%q -> %q;\n", v, t)
+		}
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// addresses returns all addresses in the graph in sorted order.
+func (g *AliasGraph) addresses() []string {
+	set := make(map[string]bool)
+	for a := range g.accounts {
+		set[a] = true
+	}
+	for from, targets := range g.edges {
+		set[from] = true
+		for _, t := range targets {
+			set[t] = true
+		}
+	}
+	addresses := make([]string, 0, len(set))
+	for a := range set {
+		addresses = append(addresses, a)
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+func normalizeAddress(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
@@ -0,0 +1,162 @@
+package goprsc
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAliasGraph_Resolve(t *testing.T) {
+	g := NewAliasGraph()
+	g.AddAccount("example.com", Account{Username: "john"})
+	g.AddAccount("example.com", Account{Username: "jane"})
+	g.AddAccount("example.org", Account{Username: "info"})
+	g.AddAlias("example.com", Alias{Name: "team", Email: "john@example.com", Enabled: true})
+	g.AddAlias("example.com", Alias{Name: "team", Email: "sales@example.org", Enabled: true})
+	g.AddAlias("example.com", Alias{Name: "team", Email: "disabled@example.com", Enabled: false})
+	g.AddAlias("example.org", Alias{Name: "sales", Email: "info@example.org", Enabled: true})
+	g.AddAlias("example.org", Alias{Name: "sales", Email: "partner@example.net", Enabled: true})
+	g.AddAlias("example.org", Alias{Name: "sales", Email: "gone@example.org", Enabled: true})
+	g.AddAlias("example.com", Alias{Name: "jane", Email: "jane@example.com", Enabled: true})
+	g.AddAlias("example.com", Alias{Name: "jane", Email: "john@example.com", Enabled: true})
+
+	testCases := []struct {
+		address    string
+		mailboxes  []string
+		external   []string
+		unresolved []string
+	}{
+		{"Team@example.com", []string{"info@example.org", "john@example.com"}, []string{"partner@example.net"}, []string{"gone@example.org"}},
+		{"jane@example.com", []string{"jane@example.com", "john@example.com"}, nil, nil},
+		{"john@example.com", []string{"john@example.com"}, nil, nil},
+	}
+
+	for _, tc := range testCases {
+		r, err := g.Resolve(tc.address)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.address, err)
+		}
+		if !reflect.DeepEqual(r.Mailboxes, tc.mailboxes) || !reflect.DeepEqual(r.External, tc.external) || !reflect.DeepEqual(r.Unresolved, tc.unresolved) {
+			t.Errorf("%s: unexpected resolution: %#v", tc.address, r)
+		}
+	}
+}
+
+func TestAliasGraph_Loops(t *testing.T) {
+	g := NewAliasGraph()
+	g.AddAlias("example.com", Alias{Name: "a", Email: "b@example.com", Enabled: true})
+	g.AddAlias("example.com", Alias{Name: "b", Email: "c@example.org", Enabled: true})
+	g.AddAlias("example.org", Alias{Name: "c", Email: "a@example.com", Enabled: true})
+
+	_, err := g.Resolve("a@example.com")
+	if _, ok := err.(*AliasLoopError); !ok {
+		t.Fatalf("expected *AliasLoopError, got: %v", err)
+	}
+
+	loops := g.Loops()
+	want := [][]string{{"a@example.com", "b@example.com", "c@example.org"}}
+	if !reflect.DeepEqual(loops, want) {
+		t.Fatalf("expected: %v, got: %v", want, loops)
+	}
+}
+
+func TestAliasGraph_MaxDepth(t *testing.T) {
+	g := NewAliasGraph()
+	g.MaxDepth = 2
+	g.AddAlias("example.com", Alias{Name: "a", Email: "b@example.com", Enabled: true})
+	g.AddAlias("example.com", Alias{Name: "b", Email: "c@example.com", Enabled: true})
+	g.AddAlias("example.com", Alias{Name: "c", Email: "d@example.com", Enabled: true})
+	g.AddAlias("example.com", Alias{Name: "d", Email: "e@example.com", Enabled: true})
+
+	_, err := g.Resolve("a@example.com")
+	if _, ok := err.(*AliasDepthError); !ok {
+		t.Fatalf("expected *AliasDepthError, got: %v", err)
+	}
+}
+
+func TestAliasGraph_Diamond(t *testing.T) {
+	// Each alias of a layer points to all aliases of the next layer, so the number of
+	// paths grows exponentially with the number of layers.
+	const layers, width = 40, 4
+	g := NewAliasGraph()
+	g.MaxDepth = layers + 1
+	g.AddAccount("example.com", Account{Username: "john"})
+	name := func(layer, i int) string { return fmt.Sprintf("l%d-%d", layer, i) }
+	g.AddAlias("example.com", Alias{Name: "top", Email: name(0, 0) + "@example.com", Enabled: true})
+	for l := 0; l < layers; l++ {
+		for i := 0; i < width; i++ {
+			for j := 0; j < width; j++ {
+				email := name(l+1, j) + "@example.com"
+				if l == layers-1 {
+					email = "john@example.com"
+				}
+				g.AddAlias("example.com", Alias{Name: name(l, i), Email: email, Enabled: true})
+			}
+		}
+	}
+
+	done := make(chan struct{})
+	var r *AliasResolution
+	var err error
+	go func() {
+		r, err = g.Resolve("top@example.com")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("resolution took too long")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r.Mailboxes, []string{"john@example.com"}) {
+		t.Fatalf("unexpected mailboxes: %v", r.Mailboxes)
+	}
+}
+
+func TestAliasGraph_WriteDOT(t *testing.T) {
+	g := NewAliasGraph()
+	g.AddAccount("example.com", Account{Username: "john"})
+	g.AddAlias("example.com", Alias{Name: "team", Email: "john@example.com", Enabled: true})
+	g.AddAlias("example.com", Alias{Name: "team", Email: "ext@example.net", Enabled: true})
+
+	var buf bytes.Buffer
+	if err := g.WriteDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{
+		`"john@example.com" [shape=box];`,
+		`"ext@example.net" [style=dashed];`,
+		`"team@example.com" -> "john@example.com";`,
+	} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("expected output to contain %s, got:\n%s", s, buf.String())
+		}
+	}
+}
+
+func TestAlias_Graph(t *testing.T) {
+	fs := setupFake()
+	defer shutdown()
+
+	fs.addDomain("example.com", true)
+	fs.addAccount("example.com", "john", true)
+	fs.addAlias("example.com", "team", "john@example.com", true)
+
+	g, err := client.Aliases.Graph()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := g.Resolve("team@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r.Mailboxes, []string{"john@example.com"}) {
+		t.Fatalf("unexpected mailboxes: %v", r.Mailboxes)
+	}
+}
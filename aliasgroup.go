@@ -0,0 +1,198 @@
+package goprsc
+
+import "fmt"
+
+// AliasGroup is an alias name together with all of its target addresses, such as a
+// distribution list. The operations of a group keep Members in sync with the server.
+type AliasGroup struct {
+	Domain string
+	Name   string
+
+	// Members are the aliases of the name, one per target address.
+	Members []Alias
+
+	service *AliasService
+}
+
+// AliasGroupChange describes the target addresses added to and removed from a group.
+type AliasGroupChange struct {
+	Added   []string
+	Removed []string
+}
+
+// Empty reports whether the change doesn't add or remove any address.
+func (c *AliasGroupChange) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0
+}
+
+// Group returns the group of aliases with the given name. The group of a name without
+// aliases is empty.
+func (s *AliasService) Group(domain, name string) (*AliasGroup, error) {
+	aliases, err := s.Get(domain, name)
+	if err != nil && !isNotFound(err) {
+		return nil, err
+	}
+	return &AliasGroup{Domain: domain, Name: name, Members: aliases, service: s}, nil
+}
+
+// Emails returns the target addresses of the group.
+func (g *AliasGroup) Emails() []string {
+	emails := make([]string, len(g.Members))
+	for i, a := range g.Members {
+		emails[i] = a.Email
+	}
+	return emails
+}
+
+// member returns the index of the member with the given target address or -1.
+func (g *AliasGroup) member(email string) int {
+	email = normalizeAddress(email)
+	for i, a := range g.Members {
+		if normalizeAddress(a.Email) == email {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddMembers adds the target addresses which aren't members of the group yet.
+func (g *AliasGroup) AddMembers(emails ...string) error {
+	for _, email := range emails {
+		if g.member(email) >= 0 {
+			continue
+		}
+		if err := g.add(email); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveMembers removes the given target addresses from the group. Addresses which
+// aren't members are ignored.
+func (g *AliasGroup) RemoveMembers(emails ...string) error {
+	for _, email := range emails {
+		if err := g.remove(email); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PlanMembers returns the changes needed to make emails the target addresses of the
+// group.
+func (g *AliasGroup) PlanMembers(emails ...string) *AliasGroupChange {
+	change := &AliasGroupChange{}
+	wanted := make(map[string]bool)
+	for _, email := range emails {
+		n := normalizeAddress(email)
+		if wanted[n] {
+			continue
+		}
+		wanted[n] = true
+		if g.member(email) < 0 {
+			change.Added = append(change.Added, email)
+		}
+	}
+	for _, a := range g.Members {
+		if !wanted[normalizeAddress(a.Email)] {
+			change.Removed = append(change.Removed, a.Email)
+		}
+	}
+	return change
+}
+
+// SetMembers makes emails the target addresses of the group with the minimal number of
+// changes. New addresses are added before old ones are removed, so that mail to the group
+// is delivered throughout. If a change fails, the changes already applied are rolled back.
+func (g *AliasGroup) SetMembers(emails ...string) (*AliasGroupChange, error) {
+	change := g.PlanMembers(emails...)
+
+	var undo undoStack
+	fail := func(err error) (*AliasGroupChange, error) {
+		if rerr := undo.rollback(); rerr != nil {
+			return nil, fmt.Errorf("%v (rollback failed: %v)", err, rerr)
+		}
+		return nil, err
+	}
+	for _, email := range change.Added {
+		email := email
+		if err := g.add(email); err != nil {
+			return fail(err)
+		}
+		undo.push(func() error { return g.remove(email) })
+	}
+	for _, email := range change.Removed {
+		a := g.Members[g.member(email)]
+		if err := g.remove(email); err != nil {
+			return fail(err)
+		}
+		undo.push(func() error { return g.restore(a) })
+	}
+	return change, nil
+}
+
+// EnableAll enables all members of the group.
+func (g *AliasGroup) EnableAll() error {
+	return g.setEnabled(true)
+}
+
+// DisableAll disables all members of the group.
+func (g *AliasGroup) DisableAll() error {
+	return g.setEnabled(false)
+}
+
+func (g *AliasGroup) setEnabled(enabled bool) error {
+	for i := range g.Members {
+		a := &g.Members[i]
+		if a.Enabled == enabled {
+			continue
+		}
+		ur := &AliasUpdateRequest{Name: a.Name, Email: a.Email, Enabled: enabled}
+		if err := g.service.Update(g.Domain, g.Name, a.Email, ur); err != nil {
+			return fmt.Errorf("update alias %s@%s -> %s: %v", g.Name, g.Domain, a.Email, err)
+		}
+		a.Enabled = enabled
+	}
+	return nil
+}
+
+func (g *AliasGroup) add(email string) error {
+	if err := g.service.Create(g.Domain, g.Name, email); err != nil {
+		return fmt.Errorf("create alias %s@%s -> %s: %v", g.Name, g.Domain, email, err)
+	}
+	a, err := g.service.GetForEmail(g.Domain, g.Name, email)
+	if err != nil {
+		a = &Alias{Name: g.Name, Email: email, Enabled: true}
+	}
+	g.Members = append(g.Members, *a)
+	return nil
+}
+
+func (g *AliasGroup) remove(email string) error {
+	i := g.member(email)
+	if i < 0 {
+		return nil
+	}
+	if err := g.service.Delete(g.Domain, g.Name, g.Members[i].Email); err != nil {
+		return fmt.Errorf("delete alias %s@%s -> %s: %v", g.Name, g.Domain, g.Members[i].Email, err)
+	}
+	g.Members = append(g.Members[:i], g.Members[i+1:]...)
+	return nil
+}
+
+// restore recreates a removed member in its previous state.
+func (g *AliasGroup) restore(a Alias) error {
+	if err := g.add(a.Email); err != nil {
+		return err
+	}
+	if a.Enabled {
+		return nil
+	}
+	m := &g.Members[len(g.Members)-1]
+	if err := g.service.Update(g.Domain, g.Name, a.Email, &AliasUpdateRequest{Name: a.Name, Email: a.Email}); err != nil {
+		return err
+	}
+	m.Enabled = false
+	return nil
+}
@@ -0,0 +1,105 @@
+package goprsc
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestAliasGroup_Members(t *testing.T) {
+	fs := setupFake()
+	defer shutdown()
+	fs.addDomain("example.com", true)
+	fs.addAlias("example.com", "team", "john@example.com", true)
+	fs.addAlias("example.com", "team", "jane@example.com", false)
+	fs.addAlias("example.com", "other", "bob@example.com", true)
+
+	g, err := client.Aliases.Group("example.com", "team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Members) != 2 {
+		t.Fatalf("expected 2 members, got: %#v", g.Members)
+	}
+
+	if err := g.AddMembers("JOHN@example.com", "bob@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.RemoveMembers("jane@example.com", "nobody@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	assertGroup(t, fs, g, "bob@example.com", "john@example.com")
+
+	change, err := g.SetMembers("john@example.com", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(change.Added, []string{"alice@example.com"}) || !reflect.DeepEqual(change.Removed, []string{"bob@example.com"}) {
+		t.Fatalf("unexpected change: %#v", change)
+	}
+	assertGroup(t, fs, g, "alice@example.com", "john@example.com")
+	if change := g.PlanMembers(g.Emails()...); !change.Empty() {
+		t.Fatalf("expected no changes, got: %#v", change)
+	}
+
+	if err := g.DisableAll(); err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range fs.aliases["example.com"] {
+		if a.Name == "team" && a.Enabled || a.Name == "other" && !a.Enabled {
+			t.Errorf("unexpected alias state: %#v", a)
+		}
+	}
+	if err := g.EnableAll(); err != nil {
+		t.Fatal(err)
+	}
+	if !fs.alias("example.com", "team", "john@example.com").Enabled {
+		t.Error("expected alias to be enabled")
+	}
+
+	empty, err := client.Aliases.Group("example.com", "new")
+	if err != nil || len(empty.Members) != 0 {
+		t.Fatalf("expected empty group, got: %#v, %v", empty, err)
+	}
+}
+
+func TestAliasGroup_SetMembersRollback(t *testing.T) {
+	fs := setupFake()
+	defer shutdown()
+	fs.addDomain("example.com", true)
+	fs.addAlias("example.com", "team", "john@example.com", true)
+	fs.addAlias("example.com", "team", "jane@example.com", false)
+
+	g, err := client.Aliases.Group("example.com", "team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs.fail = func(r *http.Request) bool {
+		return r.Method == http.MethodDelete && strings.HasSuffix(r.URL.Path, "/john@example.com")
+	}
+	if _, err := g.SetMembers("bob@example.com"); err == nil {
+		t.Fatal("expected SetMembers to fail")
+	}
+	assertGroup(t, fs, g, "jane@example.com", "john@example.com")
+	if fs.alias("example.com", "team", "jane@example.com").Enabled {
+		t.Error("expected restored member to stay disabled")
+	}
+}
+
+func assertGroup(t *testing.T, fs *fakeServer, g *AliasGroup, emails ...string) {
+	t.Helper()
+	local := g.Emails()
+	sort.Strings(local)
+	var server []string
+	for _, a := range fs.aliases[g.Domain] {
+		if a.Name == g.Name {
+			server = append(server, a.Email)
+		}
+	}
+	sort.Strings(server)
+	if !reflect.DeepEqual(local, emails) || !reflect.DeepEqual(server, emails) {
+		t.Fatalf("expected members: %v, got: %v (server: %v)", emails, local, server)
+	}
+}
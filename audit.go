@@ -0,0 +1,493 @@
+package goprsc
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"text/tabwriter"
+)
+
+// Severity is the severity of an audit finding.
+type Severity int
+
+// Audit finding severities.
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+var severityNames = []string{"info", "warning", "error"}
+
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (s *Severity) UnmarshalText(text []byte) error {
+	for i, name := range severityNames {
+		if name == string(text) {
+			*s = Severity(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown severity %q", text)
+}
+
+// AuditFinding is a problem found by an audit rule.
+type AuditFinding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+
+	// Object identifies the offending object (e.g. "alias sales@example.com").
+	Object string `json:"object"`
+
+	Message string `json:"message"`
+
+	// Fix is a suggested fix for the problem.
+	Fix string `json:"fix,omitempty"`
+}
+
+// AuditRule checks a snapshot of the server state for problems.
+type AuditRule interface {
+	// Name returns the unique name of the rule.
+	Name() string
+
+	// Check returns the problems found in the snapshot.
+	Check(s *Snapshot) []AuditFinding
+}
+
+type auditRule struct {
+	name  string
+	check func(s *Snapshot) []AuditFinding
+}
+
+func (r *auditRule) Name() string {
+	return r.name
+}
+
+func (r *auditRule) Check(s *Snapshot) []AuditFinding {
+	findings := r.check(s)
+	for i := range findings {
+		findings[i].Rule = r.name
+	}
+	return findings
+}
+
+// NewAuditRule returns an AuditRule with the given name which uses check to find problems.
+// The Rule field of the returned findings is set to name.
+func NewAuditRule(name string, check func(s *Snapshot) []AuditFinding) AuditRule {
+	return &auditRule{name: name, check: check}
+}
+
+// AuditSuppression suppresses findings of a rule for matching objects.
+type AuditSuppression struct {
+	// Rule is the name of the suppressed rule. Empty matches all rules.
+	Rule string `json:"rule"`
+
+	// Object is a path.Match pattern for the suppressed objects. Empty matches all objects.
+	Object string `json:"object"`
+
+	// Reason documents why the findings are suppressed.
+	Reason string `json:"reason"`
+}
+
+func (s AuditSuppression) matches(f AuditFinding) bool {
+	if len(s.Rule) > 0 && s.Rule != f.Rule {
+		return false
+	}
+	if len(s.Object) == 0 {
+		return true
+	}
+	ok, err := path.Match(s.Object, f.Object)
+	return err == nil && ok
+}
+
+// Auditor runs audit rules against server snapshots.
+type Auditor struct {
+	// Rules are the rules to run (defaults to DefaultAuditRules).
+	Rules []AuditRule
+
+	// Suppressions are applied to the findings of all rules.
+	Suppressions []AuditSuppression
+
+	// CatchAllName is the catch-all alias name checked by the default rules (defaults to
+	// DefaultCatchAllAliasName).
+	CatchAllName string
+}
+
+// AuditReport is the result of an audit.
+type AuditReport struct {
+	// Rules are the names of the rules which were run.
+	Rules []string `json:"rules"`
+
+	Findings   []AuditFinding `json:"findings"`
+	Suppressed []AuditFinding `json:"suppressed,omitempty"`
+}
+
+// Run checks the snapshot with all rules of the auditor.
+func (a *Auditor) Run(s *Snapshot) *AuditReport {
+	rules := a.Rules
+	if rules == nil {
+		rules = defaultAuditRules(a.CatchAllName)
+	}
+
+	r := &AuditReport{Findings: []AuditFinding{}}
+	for _, rule := range rules {
+		r.Rules = append(r.Rules, rule.Name())
+	findings:
+		for _, f := range rule.Check(s) {
+			for _, sup := range a.Suppressions {
+				if sup.matches(f) {
+					r.Suppressed = append(r.Suppressed, f)
+					continue findings
+				}
+			}
+			r.Findings = append(r.Findings, f)
+		}
+	}
+	return r
+}
+
+// Audit takes a snapshot of the server and checks it with the given auditor. A nil
+// auditor runs the default rules with the catch-all alias name of the client.
+func (c *Client) Audit(a *Auditor) (*AuditReport, error) {
+	s, err := c.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	if a == nil {
+		a = &Auditor{CatchAllName: c.CatchAllName()}
+	}
+	return a.Run(s), nil
+}
+
+// Failed reports whether the report contains findings with at least the given severity.
+func (r *AuditReport) Failed(min Severity) bool {
+	for _, f := range r.Findings {
+		if f.Severity >= min {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteText writes the findings as a human readable table.
+func (r *AuditReport) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, f := range r.Findings {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", strings.ToUpper(f.Severity.String()), f.Rule, f.Object, f.Message)
+		if len(f.Fix) > 0 {
+			fmt.Fprintf(tw, "\t\t\tfix: %s\n", f.Fix)
+		}
+	}
+	fmt.Fprintf(tw, "%d findings, %d suppressed\n", len(r.Findings), len(r.Suppressed))
+	return tw.Flush()
+}
+
+// WriteJSON writes the report as JSON.
+func (r *AuditReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitFailure `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report in JUnit XML format. Each rule is a test suite and each
+// finding a test case. Warnings and errors are reported as failures, suppressed findings
+// as skipped tests and rules without findings as passed tests.
+func (r *AuditReport) WriteJUnit(w io.Writer) error {
+	var names []string
+	suites := make(map[string]*junitTestSuite)
+	suite := func(rule string) *junitTestSuite {
+		s, ok := suites[rule]
+		if !ok {
+			s = &junitTestSuite{Name: rule}
+			suites[rule] = s
+			names = append(names, rule)
+		}
+		return s
+	}
+	for _, rule := range r.Rules {
+		suite(rule)
+	}
+
+	for _, f := range r.Findings {
+		s := suite(f.Rule)
+		tc := junitTestCase{ClassName: f.Rule, Name: f.Object}
+		if f.Severity >= SeverityWarning {
+			tc.Failure = &junitFailure{Message: f.Message, Type: f.Severity.String(), Text: f.Fix}
+			s.Failures++
+		} else {
+			tc.SystemOut = f.Message
+		}
+		s.Cases = append(s.Cases, tc)
+	}
+	for _, f := range r.Suppressed {
+		s := suite(f.Rule)
+		s.Cases = append(s.Cases, junitTestCase{ClassName: f.Rule, Name: f.Object, Skipped: &junitFailure{Message: f.Message}})
+		s.Skipped++
+	}
+	result := junitTestSuites{}
+	for _, name := range names {
+		s := suites[name]
+		if len(s.Cases) == 0 {
+			s.Cases = append(s.Cases, junitTestCase{ClassName: s.Name, Name: s.Name})
+		}
+		s.Tests = len(s.Cases)
+		result.Suites = append(result.Suites, *s)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(result); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// DefaultAuditRules returns the built-in audit rules.
+func DefaultAuditRules() []AuditRule {
+	return defaultAuditRules(DefaultCatchAllAliasName)
+}
+
+func defaultAuditRules(catchAllName string) []AuditRule {
+	return []AuditRule{
+		NewAuditRule("dangling-alias", checkDanglingAliases),
+		NewAuditRule("alias-to-disabled-account", checkAliasesToDisabledAccounts),
+		NewAuditRule("alias-loop", checkAliasLoops),
+		NewAuditRule("bcc-on-disabled-account", checkBccsOnDisabledAccounts),
+		NewAuditRule("account-in-disabled-domain", checkAccountsInDisabledDomains),
+		CatchAllRule(catchAllName),
+	}
+}
+
+// auditIndex holds lookup tables of the addresses in a snapshot.
+type auditIndex struct {
+	domains  map[string]*DomainSnapshot
+	accounts map[string]*AccountSnapshot
+	aliases  map[string]bool
+}
+
+func newAuditIndex(s *Snapshot) *auditIndex {
+	idx := &auditIndex{
+		domains:  make(map[string]*DomainSnapshot),
+		accounts: make(map[string]*AccountSnapshot),
+		aliases:  make(map[string]bool),
+	}
+	for i := range s.Domains {
+		d := &s.Domains[i]
+		idx.domains[normalizeAddress(d.Name)] = d
+		for j := range d.Accounts {
+			idx.accounts[normalizeAddress(d.Accounts[j].Username+"@"+d.Name)] = &d.Accounts[j]
+		}
+		for _, a := range d.Aliases {
+			idx.aliases[normalizeAddress(a.Name+"@"+d.Name)] = true
+		}
+	}
+	return idx
+}
+
+func checkDanglingAliases(s *Snapshot) []AuditFinding {
+	idx := newAuditIndex(s)
+	var findings []AuditFinding
+	for _, d := range s.Domains {
+		for _, a := range d.Aliases {
+			target := normalizeAddress(a.Email)
+			_, domain := splitAddress(target)
+			if idx.domains[domain] == nil || idx.accounts[target] != nil || idx.aliases[target] {
+				continue
+			}
+			findings = append(findings, AuditFinding{
+				Severity: SeverityError,
+				Object:   fmt.Sprintf("alias %s@%s -> %s", a.Name, d.Name, a.Email),
+				Message:  fmt.Sprintf("target %s is neither an account nor an alias", a.Email),
+				Fix:      "delete the alias or create the target account",
+			})
+		}
+	}
+	return findings
+}
+
+func checkAliasesToDisabledAccounts(s *Snapshot) []AuditFinding {
+	idx := newAuditIndex(s)
+	var findings []AuditFinding
+	for _, d := range s.Domains {
+		for _, a := range d.Aliases {
+			if !a.Enabled {
+				continue
+			}
+			if account := idx.accounts[normalizeAddress(a.Email)]; account != nil && !account.Enabled {
+				findings = append(findings, AuditFinding{
+					Severity: SeverityWarning,
+					Object:   fmt.Sprintf("alias %s@%s -> %s", a.Name, d.Name, a.Email),
+					Message:  fmt.Sprintf("target account %s is disabled", a.Email),
+					Fix:      "disable the alias or enable the target account",
+				})
+			}
+		}
+	}
+	return findings
+}
+
+func checkAliasLoops(s *Snapshot) []AuditFinding {
+	var findings []AuditFinding
+	for _, loop := range s.AliasGraph().Loops() {
+		findings = append(findings, AuditFinding{
+			Severity: SeverityError,
+			Object:   "alias " + loop[0],
+			Message:  fmt.Sprintf("alias loop between %s", strings.Join(loop, ", ")),
+			Fix:      "remove one of the aliases forming the loop",
+		})
+	}
+	return findings
+}
+
+func checkBccsOnDisabledAccounts(s *Snapshot) []AuditFinding {
+	var findings []AuditFinding
+	for _, d := range s.Domains {
+		for _, a := range d.Accounts {
+			if a.Enabled {
+				continue
+			}
+			for _, b := range []struct {
+				bccType string
+				bcc     *Bcc
+			}{{IncomingBccType, a.IncomingBcc}, {OutgoingBccType, a.OutgoingBcc}} {
+				if b.bcc == nil || !b.bcc.Enabled {
+					continue
+				}
+				findings = append(findings, AuditFinding{
+					Severity: SeverityWarning,
+					Object:   fmt.Sprintf("%s bcc %s@%s", b.bccType, a.Username, d.Name),
+					Message:  fmt.Sprintf("enabled %s bcc to %s on disabled account", b.bccType, b.bcc.Email),
+					Fix:      "disable or delete the bcc",
+				})
+			}
+		}
+	}
+	return findings
+}
+
+func checkAccountsInDisabledDomains(s *Snapshot) []AuditFinding {
+	var findings []AuditFinding
+	for _, d := range s.Domains {
+		if d.Enabled {
+			continue
+		}
+		for _, a := range d.Accounts {
+			if !a.Enabled {
+				continue
+			}
+			findings = append(findings, AuditFinding{
+				Severity: SeverityWarning,
+				Object:   fmt.Sprintf("account %s@%s", a.Username, d.Name),
+				Message:  fmt.Sprintf("enabled account in disabled domain %s", d.Name),
+				Fix:      "disable the account or enable the domain",
+			})
+		}
+	}
+	return findings
+}
+
+// CatchAllRule returns an audit rule warning about enabled catch-all aliases with the
+// given name in domains with enabled accounts. Postfix matches the catch-all before the
+// mailboxes, so accounts without an alias to themselves are unreachable, and a catch-all
+// accepts the spam sent to guessed addresses.
+func CatchAllRule(name string) AuditRule {
+	return NewAuditRule("catch-all", func(s *Snapshot) []AuditFinding {
+		return checkCatchAlls(s, name)
+	})
+}
+
+func checkCatchAlls(s *Snapshot, name string) []AuditFinding {
+	var findings []AuditFinding
+	for _, d := range s.Domains {
+		self := make(map[string]bool)
+		var catchAlls []Alias
+		for _, a := range d.Aliases {
+			if !a.Enabled {
+				continue
+			}
+			if a.Name == name {
+				catchAlls = append(catchAlls, a)
+			} else if normalizeAddress(a.Name+"@"+d.Name) == normalizeAddress(a.Email) {
+				self[normalizeAddress(a.Name)] = true
+			}
+		}
+		if len(catchAlls) == 0 {
+			continue
+		}
+
+		var enabled int
+		var shadowed []string
+		for _, a := range d.Accounts {
+			if !a.Enabled {
+				continue
+			}
+			enabled++
+			if !self[normalizeAddress(a.Username)] {
+				shadowed = append(shadowed, a.Username+"@"+d.Name)
+			}
+		}
+		if enabled == 0 {
+			continue
+		}
+
+		for _, a := range catchAlls {
+			f := AuditFinding{
+				Severity: SeverityWarning,
+				Object:   fmt.Sprintf("alias %s@%s -> %s", a.Name, d.Name, a.Email),
+				Message:  fmt.Sprintf("catch-all alongside %d enabled accounts accepts mail for any address and attracts spam", enabled),
+				Fix:      "remove the catch-all",
+			}
+			if len(shadowed) > 0 {
+				f.Message = fmt.Sprintf("catch-all makes accounts without an alias to themselves unreachable: %s", strings.Join(shadowed, ", "))
+				f.Fix = "remove the catch-all or add an alias from each account to itself"
+			}
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
@@ -0,0 +1,160 @@
+package goprsc
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func auditSnapshot() *Snapshot {
+	return &Snapshot{Domains: []DomainSnapshot{
+		{
+			Domain: Domain{Name: "example.com", Enabled: true},
+			Accounts: []AccountSnapshot{
+				{Account: Account{Username: "john", Enabled: true}},
+				{Account: Account{Username: "jane", Enabled: false}, IncomingBcc: &Bcc{Email: "archive@example.com", Enabled: true}},
+			},
+			Aliases: []Alias{
+				{Name: "team", Email: "john@example.com", Enabled: true},
+				{Name: "team", Email: "jane@example.com", Enabled: true},
+				{Name: "old", Email: "gone@example.com", Enabled: true},
+				{Name: "a", Email: "b@example.com", Enabled: true},
+				{Name: "b", Email: "a@example.com", Enabled: true},
+				{Name: "ext", Email: "someone@example.net", Enabled: true},
+			},
+		},
+		{
+			Domain:   Domain{Name: "example.org", Enabled: false},
+			Accounts: []AccountSnapshot{{Account: Account{Username: "info", Enabled: true}}},
+		},
+	}}
+}
+
+func findingRules(findings []AuditFinding) map[string]int {
+	rules := make(map[string]int)
+	for _, f := range findings {
+		rules[f.Rule]++
+	}
+	return rules
+}
+
+func TestAuditor_Run(t *testing.T) {
+	r := (&Auditor{}).Run(auditSnapshot())
+
+	expected := map[string]int{
+		"dangling-alias":             1,
+		"alias-to-disabled-account":  1,
+		"alias-loop":                 1,
+		"bcc-on-disabled-account":    1,
+		"account-in-disabled-domain": 1,
+	}
+	got := findingRules(r.Findings)
+	for rule, n := range expected {
+		if got[rule] != n {
+			t.Errorf("%s: expected %d findings, got: %d", rule, n, got[rule])
+		}
+	}
+	if !r.Failed(SeverityError) {
+		t.Error("expected report to fail")
+	}
+}
+
+func TestAuditor_Suppressions(t *testing.T) {
+	a := &Auditor{
+		Rules: DefaultAuditRules(),
+		Suppressions: []AuditSuppression{
+			{Rule: "dangling-alias", Object: "alias old@example.com -> *"},
+			{Rule: "alias-loop"},
+		},
+	}
+	r := a.Run(auditSnapshot())
+
+	if len(r.Suppressed) != 2 {
+		t.Fatalf("expected 2 suppressed findings, got: %v", r.Suppressed)
+	}
+	if r.Failed(SeverityError) {
+		t.Errorf("expected no errors, got: %v", r.Findings)
+	}
+	if !r.Failed(SeverityWarning) {
+		t.Error("expected warnings")
+	}
+}
+
+func TestAuditor_CustomRule(t *testing.T) {
+	rule := NewAuditRule("no-domains", func(s *Snapshot) []AuditFinding {
+		if len(s.Domains) > 0 {
+			return nil
+		}
+		return []AuditFinding{{Severity: SeverityInfo, Object: "server", Message: "no domains"}}
+	})
+	r := (&Auditor{Rules: []AuditRule{rule}}).Run(&Snapshot{})
+
+	if len(r.Findings) != 1 || r.Findings[0].Rule != "no-domains" {
+		t.Fatalf("unexpected findings: %v", r.Findings)
+	}
+	if r.Failed(SeverityWarning) {
+		t.Error("info findings must not fail the report")
+	}
+}
+
+func TestAuditReport_Write(t *testing.T) {
+	r := (&Auditor{Suppressions: []AuditSuppression{{Rule: "alias-loop"}}}).Run(auditSnapshot())
+
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "ERROR") || !strings.Contains(buf.String(), "fix: ") {
+		t.Errorf("unexpected text output:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := r.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded AuditReport
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Findings) != len(r.Findings) || decoded.Findings[0].Severity != r.Findings[0].Severity {
+		t.Errorf("unexpected JSON output:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := r.WriteJUnit(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var suites junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+		t.Fatal(err)
+	}
+	if len(suites.Suites) != len(DefaultAuditRules()) {
+		t.Fatalf("expected %d suites, got: %d", len(DefaultAuditRules()), len(suites.Suites))
+	}
+	for _, s := range suites.Suites {
+		if s.Name == "alias-loop" && s.Skipped != 1 {
+			t.Errorf("expected suppressed alias-loop finding to be skipped: %#v", s)
+		}
+		if s.Name == "dangling-alias" && s.Failures != 1 {
+			t.Errorf("expected dangling-alias failure: %#v", s)
+		}
+	}
+}
+
+func TestClient_Audit(t *testing.T) {
+	fs := setupFake()
+	defer shutdown()
+
+	fs.addDomain("example.com", true)
+	fs.addAlias("example.com", "team", "nobody@example.com", true)
+
+	r, err := client.Audit(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if findingRules(r.Findings)["dangling-alias"] != 1 {
+		t.Fatalf("unexpected findings: %v", r.Findings)
+	}
+}
@@ -0,0 +1,235 @@
+package goprsc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	backupPrefix     = "snapshot-"
+	backupSuffix     = ".json"
+	backupTimeLayout = "20060102T150405Z"
+)
+
+// BackupRetention configures how many snapshots are kept. The newest snapshot of each of
+// the last Hourly hours, Daily days and Weekly weeks is kept. The latest snapshot is
+// always kept. If all values are zero, no snapshots are removed.
+type BackupRetention struct {
+	Hourly int
+	Daily  int
+	Weekly int
+}
+
+// Backup periodically stores snapshots of the server state in a directory.
+type Backup struct {
+	// Client is the client used to read the server state.
+	Client *Client
+
+	// Dir is the directory in which the snapshot files are stored.
+	Dir string
+
+	// Interval is the time between two snapshots (defaults to one hour).
+	Interval time.Duration
+
+	// Retention configures which snapshot files are kept.
+	Retention BackupRetention
+
+	// ErrorHandler, if set, is called with the errors from the periodic backups.
+	ErrorHandler func(err error)
+
+	mu          sync.Mutex
+	lastSuccess time.Time
+	lastErr     error
+
+	// now returns the current time. It is replaced in tests.
+	now func() time.Time
+}
+
+// BackupFile is a snapshot file in the backup directory.
+type BackupFile struct {
+	Path string
+	Time time.Time
+}
+
+// Run takes a snapshot immediately and then every Interval until ctx is done. It returns
+// ctx.Err() when stopped.
+func (b *Backup) Run(ctx context.Context) error {
+	interval := b.Interval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := b.RunOnce(); err != nil && b.ErrorHandler != nil {
+			b.ErrorHandler(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce takes a snapshot and stores it unless it is identical to the latest stored
+// snapshot. Old snapshots are removed according to the retention. It reports whether a
+// new snapshot file was written.
+func (b *Backup) RunOnce() (bool, error) {
+	written, err := b.backup()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lastErr = err
+	if err == nil {
+		b.lastSuccess = b.time()
+	}
+	return written, err
+}
+
+func (b *Backup) backup() (bool, error) {
+	if len(b.Dir) == 0 {
+		return false, errors.New("backup directory not set")
+	}
+	if err := os.MkdirAll(b.Dir, 0700); err != nil {
+		return false, err
+	}
+
+	s, err := b.Client.Snapshot()
+	if err != nil {
+		return false, err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return false, err
+	}
+
+	files, err := b.Files()
+	if err != nil {
+		return false, err
+	}
+	if len(files) > 0 {
+		latest, err := ioutil.ReadFile(files[0].Path)
+		if err != nil {
+			return false, err
+		}
+		if bytes.Equal(latest, data) {
+			return false, nil
+		}
+	}
+
+	name := backupPrefix + b.time().UTC().Format(backupTimeLayout) + backupSuffix
+	if err := writeFileAtomic(filepath.Join(b.Dir, name), data, 0600); err != nil {
+		return false, err
+	}
+	return true, b.prune()
+}
+
+// LastSuccess returns the time of the last successful backup, whether or not it
+// resulted in a new snapshot file. It returns the zero time if no backup succeeded yet.
+func (b *Backup) LastSuccess() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastSuccess
+}
+
+// LastError returns the error of the last backup or nil if it succeeded.
+func (b *Backup) LastError() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastErr
+}
+
+// Files returns the snapshot files in the backup directory, newest first.
+func (b *Backup) Files() ([]BackupFile, error) {
+	entries, err := ioutil.ReadDir(b.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []BackupFile
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupSuffix) {
+			continue
+		}
+		t, err := time.Parse(backupTimeLayout, strings.TrimSuffix(strings.TrimPrefix(name, backupPrefix), backupSuffix))
+		if err != nil {
+			continue
+		}
+		files = append(files, BackupFile{Path: filepath.Join(b.Dir, name), Time: t})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Time.After(files[j].Time)
+	})
+	return files, nil
+}
+
+// prune removes the snapshot files which are not kept by the retention.
+func (b *Backup) prune() error {
+	r := b.Retention
+	if r.Hourly == 0 && r.Daily == 0 && r.Weekly == 0 {
+		return nil
+	}
+
+	files, err := b.Files()
+	if err != nil {
+		return err
+	}
+
+	keep := make(map[string]bool)
+	if len(files) > 0 {
+		keep[files[0].Path] = true
+	}
+	buckets := []struct {
+		n   int
+		key func(t time.Time) string
+	}{
+		{r.Hourly, func(t time.Time) string { return t.Format("2006010215") }},
+		{r.Daily, func(t time.Time) string { return t.Format("20060102") }},
+		{r.Weekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-%02d", year, week)
+		}},
+	}
+	for _, bucket := range buckets {
+		seen := make(map[string]bool)
+		for _, f := range files {
+			if len(seen) >= bucket.n {
+				break
+			}
+			k := bucket.key(f.Time)
+			if !seen[k] {
+				seen[k] = true
+				keep[f.Path] = true
+			}
+		}
+	}
+
+	for _, f := range files {
+		if !keep[f.Path] {
+			if err := os.Remove(f.Path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (b *Backup) time() time.Time {
+	if b.now != nil {
+		return b.now()
+	}
+	return time.Now()
+}
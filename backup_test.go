@@ -0,0 +1,127 @@
+package goprsc
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestBackup_RunOnce(t *testing.T) {
+	fs := setupFake()
+	defer shutdown()
+	fs.addDomain("example.com", true)
+
+	dir, err := ioutil.TempDir("", "goprsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2017, 1, 2, 10, 0, 0, 0, time.UTC)
+	b := &Backup{Client: client, Dir: dir, now: func() time.Time { return now }}
+
+	if written, err := b.RunOnce(); err != nil || !written {
+		t.Fatalf("expected first snapshot to be written, got: %v, %v", written, err)
+	}
+
+	now = now.Add(time.Hour)
+	if written, err := b.RunOnce(); err != nil || written {
+		t.Fatalf("expected unchanged snapshot to be skipped, got: %v, %v", written, err)
+	}
+	if !b.LastSuccess().Equal(now) {
+		t.Fatalf("expected last success: %v, got: %v", now, b.LastSuccess())
+	}
+
+	fs.addAccount("example.com", "john", true)
+	now = now.Add(time.Hour)
+	if written, err := b.RunOnce(); err != nil || !written {
+		t.Fatalf("expected changed snapshot to be written, got: %v, %v", written, err)
+	}
+
+	files, err := b.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || !files[0].Time.Equal(now) {
+		t.Fatalf("unexpected files: %v", files)
+	}
+}
+
+func TestBackup_Retention(t *testing.T) {
+	fs := setupFake()
+	defer shutdown()
+
+	dir, err := ioutil.TempDir("", "goprsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2017, 1, 2, 0, 30, 0, 0, time.UTC)
+	b := &Backup{
+		Client:    client,
+		Dir:       dir,
+		Retention: BackupRetention{Hourly: 2, Daily: 2},
+		now:       func() time.Time { return now },
+	}
+
+	// Take a snapshot every 6 hours over three days, changing the state each time.
+	for i := 0; i < 12; i++ {
+		fs.addDomain(fmt.Sprintf("d%d.example.com", i), true)
+		if _, err := b.RunOnce(); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(6 * time.Hour)
+	}
+
+	files, err := b.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The two newest snapshots are kept by the hourly rule, the newest of them is also
+	// the newest of its day and the newest snapshot of the previous day is kept.
+	var times []string
+	for _, f := range files {
+		times = append(times, f.Time.Format(backupTimeLayout))
+	}
+	expected := []string{"20170104T183000Z", "20170104T123000Z", "20170103T183000Z"}
+	if len(times) != len(expected) {
+		t.Fatalf("expected: %v, got: %v", expected, times)
+	}
+	for i := range expected {
+		if times[i] != expected[i] {
+			t.Fatalf("expected: %v, got: %v", expected, times)
+		}
+	}
+}
+
+func TestBackup_Run(t *testing.T) {
+	fs := setupFake()
+	defer shutdown()
+	fs.addDomain("example.com", true)
+
+	dir, err := ioutil.TempDir("", "goprsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &Backup{Client: client, Dir: dir, Interval: time.Millisecond}
+	done := make(chan error)
+	go func() { done <- b.Run(ctx) }()
+
+	for b.LastSuccess().IsZero() {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("expected: %v, got: %v", context.Canceled, err)
+	}
+	if b.LastError() != nil {
+		t.Fatal(b.LastError())
+	}
+}
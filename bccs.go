@@ -6,10 +6,16 @@ import (
 )
 
 const (
-	inputBccType  = "incoming"
-	outputBccType = "outgoing"
+	// IncomingBccType is the type of BCCs applied to messages received by an account.
+	IncomingBccType = "incoming"
+
+	// OutgoingBccType is the type of BCCs applied to messages sent by an account.
+	OutgoingBccType = "outgoing"
 )
 
+// bccTypes lists all supported BCC types.
+var bccTypes = []string{IncomingBccType, OutgoingBccType}
+
 type BccService interface {
 	// Get makes a GET request and fetches the specified BCC.
 	Get(domain, account string) (*Bcc, error)
@@ -24,6 +30,19 @@ type BccService interface {
 	Delete(domain, account string) error
 }
 
+// BccLister lists and bulk updates the BCCs of one type. It is implemented by
+// IncomingBccService and OutgoingBccService.
+type BccLister interface {
+	// List returns the BCCs of all accounts in the domain.
+	List(domain string) ([]AccountBcc, error)
+
+	// ListAll returns the BCCs of all accounts in all domains.
+	ListAll() ([]AccountBcc, error)
+
+	// SetEnabled enables or disables all BCCs in the domain and returns the changed ones.
+	SetEnabled(domain string, enabled bool) ([]AccountBcc, error)
+}
+
 type bccServiceImpl struct {
 	client  *Client
 	bccType string
@@ -39,7 +58,7 @@ func NewIncomingBccService(c *Client) *IncomingBccService {
 	return &IncomingBccService{
 		bccServiceImpl: &bccServiceImpl{
 			client:  c,
-			bccType: inputBccType,
+			bccType: IncomingBccType,
 		},
 	}
 }
@@ -54,7 +73,7 @@ func NewOutgoingBccService(c *Client) *OutgoingBccService {
 	return &OutgoingBccService{
 		bccServiceImpl: &bccServiceImpl{
 			client:  c,
-			bccType: outputBccType,
+			bccType: OutgoingBccType,
 		},
 	}
 }
@@ -129,6 +148,128 @@ func (s *bccServiceImpl) Delete(domain, account string) error {
 	return err
 }
 
+// List returns the BCCs of all accounts in the domain. The API has no domain-wide BCC
+// endpoint, so one request is made per account.
+func (s *bccServiceImpl) List(domain string) ([]AccountBcc, error) {
+	accounts, err := s.client.Accounts.List(domain)
+	if err != nil {
+		return nil, err
+	}
+	return s.client.listAccountBccs(domain, accounts, s.bccType)
+}
+
+// ListAll returns the BCCs of all accounts in all domains.
+func (s *bccServiceImpl) ListAll() ([]AccountBcc, error) {
+	domains, err := s.client.Domains.List()
+	if err != nil {
+		return nil, err
+	}
+	var bccs []AccountBcc
+	for _, d := range domains {
+		list, err := s.List(d.Name)
+		if err != nil {
+			return nil, err
+		}
+		bccs = append(bccs, list...)
+	}
+	return bccs, nil
+}
+
+// SetEnabled enables or disables all BCCs in the domain and returns the changed ones. BCCs
+// already in the requested state are left untouched. If an update fails, the BCCs
+// changed so far are returned with the error.
+func (s *bccServiceImpl) SetEnabled(domain string, enabled bool) ([]AccountBcc, error) {
+	bccs, err := s.List(domain)
+	if err != nil {
+		return nil, err
+	}
+	var changed []AccountBcc
+	for _, b := range bccs {
+		if b.Bcc.Enabled == enabled {
+			continue
+		}
+		if err := s.Update(domain, b.Account, &BccUpdateRequest{Email: b.Bcc.Email, Enabled: enabled}); err != nil {
+			return changed, fmt.Errorf("update %s bcc of %s@%s: %v", s.bccType, b.Account, domain, err)
+		}
+		b.Bcc.Enabled = enabled
+		changed = append(changed, b)
+	}
+	return changed, nil
+}
+
+// AccountBccs is the combined view of the incoming and outgoing BCC of an account.
+type AccountBccs struct {
+	Domain  string
+	Account string
+
+	// Incoming and Outgoing are nil if the account has no BCC of the respective type.
+	Incoming *Bcc
+	Outgoing *Bcc
+}
+
+// ListBccs returns the incoming and outgoing BCCs of the accounts in the domain. Accounts
+// without BCCs are omitted.
+func (c *Client) ListBccs(domain string) ([]AccountBccs, error) {
+	accounts, err := c.Accounts.List(domain)
+	if err != nil {
+		return nil, err
+	}
+	bccs, err := c.listAccountBccs(domain, accounts, bccTypes...)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []AccountBccs
+	index := make(map[string]int)
+	for _, b := range bccs {
+		i, ok := index[b.Account]
+		if !ok {
+			i = len(list)
+			index[b.Account] = i
+			list = append(list, AccountBccs{Domain: domain, Account: b.Account})
+		}
+		bcc := b.Bcc
+		if b.Type == IncomingBccType {
+			list[i].Incoming = &bcc
+		} else {
+			list[i].Outgoing = &bcc
+		}
+	}
+	return list, nil
+}
+
+// ListAllBccs returns the incoming and outgoing BCCs of the accounts in all domains.
+func (c *Client) ListAllBccs() ([]AccountBccs, error) {
+	domains, err := c.Domains.List()
+	if err != nil {
+		return nil, err
+	}
+	var list []AccountBccs
+	for _, d := range domains {
+		bccs, err := c.ListBccs(d.Name)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, bccs...)
+	}
+	return list, nil
+}
+
 func (s *bccServiceImpl) getBccsURL(domain, username string) string {
 	return fmt.Sprintf("%s/%s/accounts/%s/bccs/%s", domainsURL, domain, username, s.bccType)
 }
+
+// bccService returns the client service handling BCCs of the given type.
+func (c *Client) bccService(bccType string) BccService {
+	if bccType == IncomingBccType {
+		return c.InputBccs
+	}
+	return c.OutputBccs
+}
+
+func (c *Client) bccLister(bccType string) BccLister {
+	if bccType == IncomingBccType {
+		return c.InputBccs
+	}
+	return c.OutputBccs
+}
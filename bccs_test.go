@@ -89,3 +89,74 @@ func TestBcc_Delete(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestBcc_List(t *testing.T) {
+	fs := setupFake()
+	defer shutdown()
+	fs.addDomain("example.com", true)
+	fs.addDomain("example.org", true)
+	fs.addAccount("example.com", "john", true)
+	fs.addAccount("example.com", "jane", true)
+	fs.addAccount("example.com", "bob", true)
+	fs.addAccount("example.org", "john", true)
+	fs.addBcc("example.com", "john", IncomingBccType, "archive@example.com", true)
+	fs.addBcc("example.com", "john", OutgoingBccType, "sent@example.com", false)
+	fs.addBcc("example.com", "jane", OutgoingBccType, "sent@example.com", true)
+	fs.addBcc("example.org", "john", OutgoingBccType, "sent@example.org", true)
+
+	bccs, err := client.OutputBccs.List("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bccs) != 2 || bccs[0].Account != "john" || bccs[1].Account != "jane" || bccs[0].Type != OutgoingBccType {
+		t.Fatalf("unexpected bccs: %#v", bccs)
+	}
+
+	all, err := client.OutputBccs.ListAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 || all[2].Domain != "example.org" {
+		t.Fatalf("unexpected bccs: %#v", all)
+	}
+
+	combined, err := client.ListBccs("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(combined) != 2 {
+		t.Fatalf("expected 2 accounts with bccs, got: %#v", combined)
+	}
+	if c := combined[0]; c.Account != "john" || c.Incoming == nil || c.Outgoing == nil || c.Incoming.Email != "archive@example.com" {
+		t.Errorf("unexpected bccs of john: %#v", c)
+	}
+	if c := combined[1]; c.Account != "jane" || c.Incoming != nil || c.Outgoing == nil {
+		t.Errorf("unexpected bccs of jane: %#v", c)
+	}
+	if combined, err := client.ListAllBccs(); err != nil || len(combined) != 3 {
+		t.Fatalf("unexpected bccs of all domains: %#v, %v", combined, err)
+	}
+
+	changed, err := client.OutputBccs.SetEnabled("example.com", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 1 || changed[0].Account != "jane" || changed[0].Bcc.Enabled {
+		t.Fatalf("unexpected changed bccs: %#v", changed)
+	}
+	if fs.bccs[bccKey(fs.account("example.com", "jane").ID, OutgoingBccType)].Enabled {
+		t.Fatal("expected bcc to be disabled")
+	}
+	if !fs.bccs[bccKey(fs.account("example.com", "john").ID, IncomingBccType)].Enabled {
+		t.Fatal("expected incoming bcc to stay enabled")
+	}
+}
+
+var (
+	_ BccService = (*IncomingBccService)(nil)
+	_ BccLister  = (*IncomingBccService)(nil)
+	_ BccService = (*OutgoingBccService)(nil)
+	_ BccLister  = (*OutgoingBccService)(nil)
+	_ BccService = (*RouterBccService)(nil)
+	_ BccLister  = (*RouterBccService)(nil)
+)
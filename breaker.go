@@ -0,0 +1,216 @@
+package goprsc
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without sending the request while the circuit breaker of
+// the client is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState is the state of a CircuitBreaker.
+type CircuitState int
+
+// States of a CircuitBreaker.
+const (
+	// CircuitClosed lets all requests through.
+	CircuitClosed CircuitState = iota
+
+	// CircuitOpen fails all requests with ErrCircuitOpen.
+	CircuitOpen
+
+	// CircuitHalfOpen lets a single trial request through to test whether the server
+	// has recovered.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreaker stops sending requests to a failing server. Network errors and 5xx
+// responses are failures. After FailureThreshold consecutive failures the circuit opens
+// and requests fail fast with ErrCircuitOpen. After OpenTimeout the circuit becomes
+// half-open and lets one trial request through at a time; SuccessThreshold consecutive
+// successful trials close it again, while a failed trial reopens it.
+type CircuitBreaker struct {
+	// FailureThreshold is the number of consecutive failures which open the circuit
+	// (defaults to 5).
+	FailureThreshold int
+
+	// OpenTimeout is the time the circuit stays open before trial requests are allowed
+	// (defaults to 30 seconds).
+	OpenTimeout time.Duration
+
+	// SuccessThreshold is the number of consecutive successful trial requests which
+	// close the circuit (defaults to 1).
+	SuccessThreshold int
+
+	// OnStateChange, if set, is called after each state change.
+	OnStateChange func(from, to CircuitState)
+
+	mu        sync.Mutex
+	state     CircuitState
+	failures  int
+	successes int
+	openedAt  time.Time
+	trial     bool
+
+	// generation is incremented on each state change. Outcomes of requests allowed in an
+	// earlier generation are ignored.
+	generation uint64
+
+	// now returns the current time. It is replaced in tests.
+	now func() time.Time
+}
+
+// CircuitBreakerOption is a client option for sending all requests through b. A breaker
+// may be shared by several clients of the same server.
+func CircuitBreakerOption(b *CircuitBreaker) ClientOption {
+	return func(c *Client) error {
+		c.breaker = b
+		return nil
+	}
+}
+
+// State returns the current state of the circuit.
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func (b *CircuitBreaker) time() time.Time {
+	if b.now != nil {
+		return b.now()
+	}
+	return time.Now()
+}
+
+// allow returns ErrCircuitOpen if the request must not be sent. Otherwise it returns the
+// generation the request is allowed in, which must be passed to record.
+func (b *CircuitBreaker) allow() (uint64, error) {
+	b.mu.Lock()
+	var change func()
+	defer func() {
+		b.mu.Unlock()
+		if change != nil {
+			change()
+		}
+	}()
+
+	timeout := b.OpenTimeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	switch b.state {
+	case CircuitOpen:
+		if b.time().Sub(b.openedAt) < timeout {
+			return 0, ErrCircuitOpen
+		}
+		change = b.setState(CircuitHalfOpen)
+		b.trial = true
+	case CircuitHalfOpen:
+		if b.trial {
+			return 0, ErrCircuitOpen
+		}
+		b.trial = true
+	}
+	return b.generation, nil
+}
+
+// record records the outcome of a request allowed by allow in the given generation. The
+// outcomes of requests allowed before the last state change are ignored, so that a request
+// allowed while the circuit was closed can't complete the trial of the half-open circuit.
+func (b *CircuitBreaker) record(generation uint64, failed bool) {
+	b.mu.Lock()
+	var change func()
+	defer func() {
+		b.mu.Unlock()
+		if change != nil {
+			change()
+		}
+	}()
+
+	if generation != b.generation {
+		return
+	}
+	failureThreshold := b.FailureThreshold
+	if failureThreshold <= 0 {
+		failureThreshold = 5
+	}
+	successThreshold := b.SuccessThreshold
+	if successThreshold <= 0 {
+		successThreshold = 1
+	}
+
+	switch b.state {
+	case CircuitClosed:
+		if !failed {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= failureThreshold {
+			change = b.open()
+		}
+	case CircuitHalfOpen:
+		b.trial = false
+		if failed {
+			change = b.open()
+			return
+		}
+		b.successes++
+		if b.successes >= successThreshold {
+			b.failures, b.successes = 0, 0
+			change = b.setState(CircuitClosed)
+		}
+	}
+}
+
+func (b *CircuitBreaker) open() func() {
+	b.openedAt = b.time()
+	b.successes = 0
+	return b.setState(CircuitOpen)
+}
+
+// setState changes the state and returns a function notifying OnStateChange, which is
+// called after the mutex is released.
+func (b *CircuitBreaker) setState(to CircuitState) func() {
+	from := b.state
+	b.state = to
+	if from != to {
+		b.generation++
+	}
+	if b.OnStateChange == nil || from == to {
+		return nil
+	}
+	return func() { b.OnStateChange(from, to) }
+}
+
+// send sends req through the circuit breaker of the client, if any. With several
+// endpoints, a request failed over to another endpoint is a single outcome for the
+// breaker.
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	if c.breaker == nil {
+		return c.transmit(req)
+	}
+	generation, err := c.breaker.allow()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.transmit(req)
+	c.breaker.record(generation, err != nil || resp.StatusCode >= 500)
+	return resp, err
+}
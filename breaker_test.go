@@ -0,0 +1,138 @@
+package goprsc
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	fs := setupFake()
+	defer shutdown()
+
+	now := time.Date(2017, 1, 2, 0, 0, 0, 0, time.UTC)
+	var changes []string
+	b := &CircuitBreaker{
+		FailureThreshold: 3,
+		OpenTimeout:      time.Minute,
+		SuccessThreshold: 2,
+		OnStateChange: func(from, to CircuitState) {
+			changes = append(changes, from.String()+"->"+to.String())
+		},
+		now: func() time.Time { return now },
+	}
+	if err := CircuitBreakerOption(b)(client); err != nil {
+		t.Fatal(err)
+	}
+
+	failing := false
+	fs.fail = func(r *http.Request) bool { return failing }
+
+	// Client errors don't count as failures.
+	for i := 0; i < 3; i++ {
+		if _, err := client.Domains.Get("missing"); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+	if b.State() != CircuitClosed {
+		t.Fatalf("expected closed circuit, got: %s", b.State())
+	}
+
+	failing = true
+	for i := 0; i < 3; i++ {
+		if _, err := client.Domains.List(); err == nil || err == ErrCircuitOpen {
+			t.Fatalf("%d: expected server error, got: %v", i, err)
+		}
+	}
+	if b.State() != CircuitOpen {
+		t.Fatalf("expected open circuit, got: %s", b.State())
+	}
+
+	n := len(fs.requests)
+	if _, err := client.Domains.List(); err != ErrCircuitOpen {
+		t.Fatalf("expected ErrCircuitOpen, got: %v", err)
+	}
+	if len(fs.requests) != n {
+		t.Fatal("expected request not to be sent while open")
+	}
+
+	// A failed trial reopens the circuit.
+	now = now.Add(time.Minute)
+	if _, err := client.Domains.List(); err == nil || err == ErrCircuitOpen {
+		t.Fatalf("expected trial request to be sent, got: %v", err)
+	}
+	if b.State() != CircuitOpen {
+		t.Fatalf("expected reopened circuit, got: %s", b.State())
+	}
+
+	now = now.Add(time.Minute)
+	failing = false
+	for i := 0; i < 2; i++ {
+		if _, err := client.Domains.List(); err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+	}
+	if b.State() != CircuitClosed {
+		t.Fatalf("expected closed circuit, got: %s", b.State())
+	}
+
+	expected := []string{
+		"closed->open",
+		"open->half-open", "half-open->open",
+		"open->half-open", "half-open->closed",
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("expected state changes: %v, got: %v", expected, changes)
+	}
+	for i := range expected {
+		if changes[i] != expected[i] {
+			t.Errorf("%d: expected: %s, got: %s", i, expected[i], changes[i])
+		}
+	}
+}
+
+func TestCircuitBreaker_SingleTrial(t *testing.T) {
+	b := &CircuitBreaker{FailureThreshold: 1}
+	b.record(0, true)
+	b.openedAt = time.Time{}
+
+	trial, err := b.allow()
+	if err != nil {
+		t.Fatalf("expected trial request to be allowed, got: %v", err)
+	}
+	if _, err := b.allow(); err != ErrCircuitOpen {
+		t.Fatalf("expected concurrent request to be rejected, got: %v", err)
+	}
+	b.record(trial, false)
+	if b.State() != CircuitClosed {
+		t.Fatalf("expected closed circuit, got: %s", b.State())
+	}
+}
+
+func TestCircuitBreaker_StaleOutcome(t *testing.T) {
+	b := &CircuitBreaker{FailureThreshold: 1}
+	stale, err := b.allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed, _ := b.allow()
+	b.record(failed, true)
+	b.openedAt = time.Time{}
+	trial, err := b.allow()
+	if err != nil || b.State() != CircuitHalfOpen {
+		t.Fatalf("expected half-open circuit, got: %s, %v", b.State(), err)
+	}
+
+	// A request allowed while the circuit was closed doesn't decide the trial.
+	b.record(stale, false)
+	if b.State() != CircuitHalfOpen {
+		t.Fatalf("expected stale success to be ignored, got: %s", b.State())
+	}
+	if _, err := b.allow(); err != ErrCircuitOpen {
+		t.Fatalf("expected the trial to be still running, got: %v", err)
+	}
+	b.record(trial, true)
+	if b.State() != CircuitOpen {
+		t.Fatalf("expected failed trial to reopen the circuit, got: %s", b.State())
+	}
+}
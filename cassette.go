@@ -0,0 +1,280 @@
+package goprsc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// CassetteMode selects whether a Cassette records or replays interactions.
+type CassetteMode int
+
+const (
+	// CassetteAuto replays the cassette file if it exists and records it otherwise.
+	CassetteAuto CassetteMode = iota
+
+	// CassetteRecord sends requests to the server and records the interactions.
+	CassetteRecord
+
+	// CassetteReplay answers requests from the recorded interactions without
+	// contacting the server.
+	CassetteReplay
+)
+
+// CassetteInteraction is a recorded request and the response to it.
+type CassetteInteraction struct {
+	Request  CassetteRequest  `json:"request"`
+	Response CassetteResponse `json:"response"`
+}
+
+// CassetteRequest is a recorded request. Body is the normalized request body with
+// passwords and tokens scrubbed.
+type CassetteRequest struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// CassetteResponse is a recorded response. Tokens and passwords in Body are scrubbed.
+// Response bodies which are not JSON are recorded verbatim in Text.
+type CassetteResponse struct {
+	StatusCode int             `json:"status"`
+	Header     http.Header     `json:"header,omitempty"`
+	Body       json.RawMessage `json:"body,omitempty"`
+	Text       string          `json:"text,omitempty"`
+}
+
+func (r *CassetteResponse) body() []byte {
+	if len(r.Text) > 0 {
+		return []byte(r.Text)
+	}
+	return r.Body
+}
+
+type cassetteFile struct {
+	Interactions []CassetteInteraction `json:"interactions"`
+}
+
+// Cassette is an http.RoundTripper which records the interactions with the server to a
+// file and replays them in later runs, so that tests written against a real Postfix REST
+// Server are fast and deterministic. Requests are matched by method, path and normalized
+// body. Identical requests are replayed in the order in which they were recorded, which
+// reproduces the unauthorized response, token refresh and retry performed by Client.Do.
+//
+// Authorization headers are not recorded and the values of all JSON fields containing
+// "password" or "token" in their name are scrubbed from request and response bodies.
+type Cassette struct {
+	// Path is the path of the cassette file.
+	Path string
+
+	// Mode selects whether the cassette records or replays interactions.
+	Mode CassetteMode
+
+	// Transport is used to send requests while recording (defaults to
+	// http.DefaultTransport).
+	Transport http.RoundTripper
+
+	mu           sync.Mutex
+	recording    bool
+	interactions []CassetteInteraction
+	used         []bool
+}
+
+// NewCassette returns a cassette for the file at path. In replay mode, the file is loaded.
+func NewCassette(path string, mode CassetteMode) (*Cassette, error) {
+	c := &Cassette{Path: path, Mode: mode}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Cassette) load() error {
+	c.recording = c.Mode == CassetteRecord
+	if c.Mode == CassetteAuto {
+		if _, err := os.Stat(c.Path); os.IsNotExist(err) {
+			c.recording = true
+		}
+	}
+	if c.recording {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(c.Path)
+	if err != nil {
+		return err
+	}
+	var f cassetteFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("cassette %s: %v", c.Path, err)
+	}
+	for i := range f.Interactions {
+		// Bodies are indented in the file.
+		r := &f.Interactions[i].Request
+		r.Body = scrubJSON(r.Body)
+	}
+	c.interactions = f.Interactions
+	c.used = make([]bool, len(f.Interactions))
+	return nil
+}
+
+// Recording reports whether the cassette sends requests to the server.
+func (c *Cassette) Recording() bool {
+	return c.recording
+}
+
+// HTTPClient returns an HTTP client using the cassette as its transport.
+func (c *Cassette) HTTPClient() *http.Client {
+	return &http.Client{Transport: c}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	key := CassetteRequest{Method: req.Method, Path: req.URL.RequestURI(), Body: scrubJSON(body)}
+
+	if c.recording {
+		return c.record(req, key)
+	}
+	return c.replay(req, key)
+}
+
+func (c *Cassette) record(req *http.Request, key CassetteRequest) (*http.Response, error) {
+	t := c.Transport
+	if t == nil {
+		t = http.DefaultTransport
+	}
+	resp, err := t.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	header := resp.Header.Clone()
+	header.Del("Date")
+	header.Del("Content-Length")
+	header.Del("Set-Cookie")
+	r := CassetteResponse{StatusCode: resp.StatusCode, Header: header}
+	if json.Valid(body) {
+		r.Body = scrubJSON(body)
+	} else {
+		r.Text = string(body)
+	}
+	c.mu.Lock()
+	c.interactions = append(c.interactions, CassetteInteraction{Request: key, Response: r})
+	c.mu.Unlock()
+	return resp, nil
+}
+
+func (c *Cassette) replay(req *http.Request, key CassetteRequest) (*http.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, in := range c.interactions {
+		if c.used[i] || !in.Request.matches(key) {
+			continue
+		}
+		c.used[i] = true
+		body := in.Response.body()
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Response.StatusCode, http.StatusText(in.Response.StatusCode)),
+			StatusCode:    in.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        in.Response.Header.Clone(),
+			Body:          ioutil.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("cassette %s: no recorded interaction for %s %s", c.Path, key.Method, key.Path)
+}
+
+// Unused returns the recorded interactions which have not been replayed.
+func (c *Cassette) Unused() []CassetteInteraction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var unused []CassetteInteraction
+	for i, in := range c.interactions {
+		if !c.used[i] {
+			unused = append(unused, in)
+		}
+	}
+	return unused
+}
+
+// Save writes the recorded interactions to the cassette file. It does nothing when
+// replaying.
+func (c *Cassette) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.recording {
+		return nil
+	}
+	data, err := json.MarshalIndent(&cassetteFile{Interactions: c.interactions}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.Path, append(data, '\n'), 0600)
+}
+
+func (r *CassetteRequest) matches(key CassetteRequest) bool {
+	return r.Method == key.Method && r.Path == key.Path && bytes.Equal(r.Body, key.Body)
+}
+
+// scrubJSON returns data normalized, with the values of fields containing "password" or
+// "token" in their name replaced. Invalid JSON data is returned as a JSON string.
+func scrubJSON(data []byte) json.RawMessage {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return json.RawMessage(fmt.Sprintf("%q", data))
+	}
+	scrubbed, err := json.Marshal(scrubValue(v))
+	if err != nil {
+		return json.RawMessage(fmt.Sprintf("%q", data))
+	}
+	return scrubbed
+}
+
+func scrubValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, fv := range v {
+			name := strings.ToLower(k)
+			if strings.Contains(name, "password") || strings.Contains(name, "token") {
+				v[k] = redacted
+			} else {
+				v[k] = scrubValue(fv)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = scrubValue(v[i])
+		}
+	}
+	return v
+}
@@ -0,0 +1,109 @@
+package goprsc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCassette_RecordReplay(t *testing.T) {
+	setup()
+
+	mux.HandleFunc("/api/v1/auth/refresh-token", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"token":"new-token","refreshToken":"new-refresh"}`)
+	})
+	mux.HandleFunc("/api/v1/domains", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer new-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		fmt.Fprint(w, `[{"id":1,"name":"example.com","enabled":true}]`)
+	})
+	mux.HandleFunc("/api/v1/domains/example.com/accounts", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	dir, err := ioutil.TempDir("", "goprsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cassette.json")
+
+	run := func(c *Cassette) *Client {
+		cl, err := NewClientWithOptions(c.HTTPClient(), HostOption(client.Host), PortOption(client.Port),
+			AuthOption("admin", "expired-token", "old-refresh"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		domains, err := cl.Domains.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(domains) != 1 || domains[0].Name != "example.com" {
+			t.Fatalf("unexpected domains: %#v", domains)
+		}
+		if err := cl.Accounts.Create("example.com", "john", "secret"); err != nil {
+			t.Fatal(err)
+		}
+		return cl
+	}
+
+	rec, err := NewCassette(path, CassetteAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rec.Recording() {
+		t.Fatal("expected a missing cassette to be recorded")
+	}
+	run(rec)
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	shutdown()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"secret", "new-token", "old-refresh", "new-refresh", "expired-token"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("cassette contains %q: %s", secret, data)
+		}
+	}
+	var f cassetteFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Interactions) != 4 || f.Interactions[0].Response.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected the refresh sequence to be recorded, got: %#v", f.Interactions)
+	}
+
+	// The server is down, so requests can only be answered from the cassette.
+	rep, err := NewCassette(path, CassetteAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Recording() {
+		t.Fatal("expected an existing cassette to be replayed")
+	}
+	cl := run(rep)
+	if cl.AuthToken != redacted {
+		t.Errorf("expected the refreshed token to be replayed, got: %s", cl.AuthToken)
+	}
+	if unused := rep.Unused(); len(unused) != 0 {
+		t.Errorf("unexpected unused interactions: %#v", unused)
+	}
+	if _, err := cl.Domains.List(); err == nil || !strings.Contains(err.Error(), "no recorded interaction") {
+		t.Errorf("expected unmatched request to fail, got: %v", err)
+	}
+}
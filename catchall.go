@@ -0,0 +1,86 @@
+package goprsc
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DefaultCatchAllAliasName is the alias name used for the catch-all alias of a domain,
+// which receives the mail for all addresses without an account or alias, unless the
+// client is configured with CatchAllOption. Postfix looks up the catch-all of a domain in
+// virtual_alias_maps as "@domain", so it is the alias with an empty name.
+const DefaultCatchAllAliasName = ""
+
+// ErrCatchAllUnsupported is returned when creating or deleting a catch-all alias with an
+// empty name. The REST API addresses aliases by name and drops empty names from requests,
+// so such aliases can only be listed. Servers whose alias map query maps "@domain" lookups
+// to a named alias can be used with CatchAllOption.
+var ErrCatchAllUnsupported = errors.New("the REST API can't store aliases with an empty name, use CatchAllOption")
+
+// CatchAllOption is a client option for using name as the catch-all alias name, for
+// servers whose virtual_alias_maps query maps the "@domain" lookup to an alias with that
+// name instead of the empty name.
+func CatchAllOption(name string) ClientOption {
+	return func(c *Client) error {
+		c.catchAllName = name
+		return nil
+	}
+}
+
+// CatchAllName returns the catch-all alias name used by the client.
+func (c *Client) CatchAllName() string {
+	return c.catchAllName
+}
+
+// IsCatchAll reports whether a is a catch-all alias.
+func (c *Client) IsCatchAll(a *Alias) bool {
+	return a.Name == c.catchAllName
+}
+
+// CreateCatchAll creates a catch-all alias forwarding the mail for unknown addresses in
+// the domain to email. It returns ErrCatchAllUnsupported if the catch-all name is empty.
+func (s *AliasService) CreateCatchAll(domain, email string) error {
+	name := s.client.CatchAllName()
+	if len(name) == 0 {
+		return ErrCatchAllUnsupported
+	}
+	return s.Create(domain, name, email)
+}
+
+// CatchAll returns the group of catch-all aliases of the domain. The group is empty if
+// the domain has no catch-all.
+func (s *AliasService) CatchAll(domain string) (*AliasGroup, error) {
+	name := s.client.CatchAllName()
+	if len(name) > 0 {
+		return s.Group(domain, name)
+	}
+
+	// Aliases with an empty name can't be requested by name, so filter the listing.
+	aliases, err := s.List(domain)
+	if err != nil {
+		return nil, err
+	}
+	g := &AliasGroup{Domain: domain, service: s}
+	for _, a := range aliases {
+		if s.client.IsCatchAll(&a) {
+			g.Members = append(g.Members, a)
+		}
+	}
+	return g, nil
+}
+
+// DeleteCatchAll removes all catch-all aliases of the domain. It returns
+// ErrCatchAllUnsupported if the catch-all name is empty.
+func (s *AliasService) DeleteCatchAll(domain string) error {
+	if len(s.client.CatchAllName()) == 0 {
+		return ErrCatchAllUnsupported
+	}
+	g, err := s.CatchAll(domain)
+	if err != nil {
+		return err
+	}
+	if err := g.RemoveMembers(g.Emails()...); err != nil {
+		return fmt.Errorf("delete catch-all of %s: %v", domain, err)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package goprsc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAliasService_CatchAll(t *testing.T) {
+	fs := setupFake()
+	defer shutdown()
+	fs.addDomain("example.com", true)
+	fs.addAlias("example.com", "team", "john@example.com", true)
+
+	g, err := client.Aliases.CatchAll("example.com")
+	if err != nil || len(g.Members) != 0 {
+		t.Fatalf("expected no catch-all, got: %#v, %v", g, err)
+	}
+
+	// The API can't address aliases with an empty name, but lists them.
+	fs.addAlias("example.com", "", "postmaster@example.net", true)
+	if g, err = client.Aliases.CatchAll("example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Members) != 1 || !client.IsCatchAll(&g.Members[0]) || g.Members[0].Email != "postmaster@example.net" {
+		t.Fatalf("unexpected catch-all: %#v", g.Members)
+	}
+	requests := len(fs.requests)
+	if err := client.Aliases.CreateCatchAll("example.com", "postmaster@example.net"); err != ErrCatchAllUnsupported {
+		t.Fatalf("expected ErrCatchAllUnsupported, got: %v", err)
+	}
+	if err := client.Aliases.DeleteCatchAll("example.com"); err != ErrCatchAllUnsupported {
+		t.Fatalf("expected ErrCatchAllUnsupported, got: %v", err)
+	}
+	if len(fs.requests) != requests {
+		t.Fatal("expected no requests for an empty catch-all name")
+	}
+}
+
+func TestAudit_CatchAll(t *testing.T) {
+	s := &Snapshot{Domains: []DomainSnapshot{
+		{
+			Domain: Domain{Name: "example.com", Enabled: true},
+			Accounts: []AccountSnapshot{
+				{Account: Account{Username: "john", Enabled: true}},
+				{Account: Account{Username: "jane", Enabled: true}},
+				{Account: Account{Username: "bob", Enabled: false}},
+			},
+			Aliases: []Alias{
+				{Name: DefaultCatchAllAliasName, Email: "postmaster@example.net", Enabled: true},
+				{Name: "john", Email: "john@example.com", Enabled: true},
+			},
+		},
+		{
+			Domain:   Domain{Name: "example.net", Enabled: true},
+			Accounts: []AccountSnapshot{{Account: Account{Username: "postmaster", Enabled: true}}},
+			Aliases: []Alias{
+				{Name: DefaultCatchAllAliasName, Email: "postmaster@example.net", Enabled: true},
+				{Name: "postmaster", Email: "postmaster@example.net", Enabled: true},
+			},
+		},
+		{
+			Domain:  Domain{Name: "example.org", Enabled: true},
+			Aliases: []Alias{{Name: DefaultCatchAllAliasName, Email: "postmaster@example.net", Enabled: true}},
+		},
+	}}
+
+	r := (&Auditor{Rules: []AuditRule{CatchAllRule(DefaultCatchAllAliasName)}}).Run(s)
+	if len(r.Findings) != 2 {
+		t.Fatalf("expected 2 findings, got: %#v", r.Findings)
+	}
+	if f := r.Findings[0]; !strings.Contains(f.Message, "jane@example.com") || strings.Contains(f.Message, "john@") || strings.Contains(f.Message, "bob@") {
+		t.Errorf("unexpected finding: %#v", f)
+	}
+	if f := r.Findings[1]; !strings.Contains(f.Message, "spam") || f.Object != "alias @example.net -> postmaster@example.net" {
+		t.Errorf("unexpected finding: %#v", f)
+	}
+}
+
+func TestCatchAllOption(t *testing.T) {
+	fs := setupFake()
+	defer shutdown()
+	fs.addDomain("example.com", true)
+	fs.addAccount("example.com", "john", true)
+	fs.addAlias("example.com", "team", "john@example.com", true)
+
+	if err := CatchAllOption("catchall")(client); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Aliases.CreateCatchAll("example.com", "postmaster@example.net"); err != nil {
+		t.Fatal(err)
+	}
+	if fs.alias("example.com", "catchall", "postmaster@example.net") == nil {
+		t.Fatal("expected catch-all with the configured name")
+	}
+
+	// Audits of the client use its catch-all name.
+	r, err := client.Audit(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, f := range r.Findings {
+		if f.Rule == "catch-all" {
+			found = strings.HasPrefix(f.Object, "alias catchall@example.com")
+		}
+	}
+	if !found {
+		t.Fatalf("expected catch-all finding, got: %#v", r.Findings)
+	}
+
+	if err := client.Aliases.DeleteCatchAll("example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if len(fs.aliases["example.com"]) != 1 || fs.aliases["example.com"][0].Name != "team" {
+		t.Fatalf("expected only the catch-all to be deleted, got: %#v", fs.aliases["example.com"])
+	}
+
+	// Other clients keep the default name.
+	if NewClient(nil).CatchAllName() != DefaultCatchAllAliasName {
+		t.Fatal("expected default name for other clients")
+	}
+}
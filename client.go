@@ -2,12 +2,14 @@ package goprsc
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"runtime"
+	"sync"
 )
 
 const (
@@ -62,6 +64,28 @@ type Client struct {
 
 	// InputBccs is the service used for communication with the input BCC API.
 	InputBccs *IncomingBccService
+
+	// observers are notified about the mutations made by the client.
+	observers []MutationObserver
+
+	// dryRun is set when mutations are recorded instead of sent.
+	dryRun *dryRun
+
+	// passwordPolicy, if set, is checked before creating accounts or changing passwords.
+	passwordPolicy *PasswordPolicy
+
+	// capabilities are the cached results of the capability probe.
+	capMu        sync.Mutex
+	capabilities *ServerCapabilities
+
+	// breaker, if set, stops sending requests to a failing server.
+	breaker *CircuitBreaker
+
+	// catchAllName is the catch-all alias name, set with CatchAllOption.
+	catchAllName string
+
+	// endpoints, if set, are the servers requests are sent to with failover.
+	endpoints *endpointPool
 }
 
 type service struct {
@@ -212,19 +236,45 @@ func (c *Client) NewRequest(method, urlStr string, body interface{}) (*http.Requ
 // Do sends a request and returns an API response. The respose is JSON decoded and stored in the value
 // pointed to by v.
 func (c *Client) Do(req *http.Request, v interface{}) (*http.Response, error) {
-	resp, err := c.client.Do(req)
+	if err := c.checkSupported(req); err != nil {
+		return nil, err
+	}
+	if !isMutation(req) || (len(c.observers) == 0 && c.dryRun == nil) {
+		return c.do(req, v)
+	}
+
+	m := c.newMutation(req)
+	if c.dryRun != nil {
+		return c.dryRun.plan(m, req)
+	}
+	resp, err := c.do(req, v)
+	m.complete(resp, err)
+	for _, o := range c.observers {
+		o(m)
+	}
+	return resp, err
+}
+
+func (c *Client) do(req *http.Request, v interface{}) (*http.Response, error) {
+	resp, err := c.send(req)
 	if err != nil {
 		return nil, err
 	}
 
 	if resp.StatusCode == http.StatusUnauthorized && len(req.Header.Get("X-GOPRSC-Refresh")) == 0 && len(c.RefreshToken) > 0 {
-		authResponse, err := c.refreshTokens()
+		// With several endpoints, the tokens are refreshed on and the request resent to
+		// the endpoint which rejected it.
+		req = c.pinEndpoint(req, resp)
+		authResponse, err := c.refreshTokens(req.Context())
 		if err != nil {
 			return nil, err
 		}
 		// Resend the original request using the new authentication token
 		req.Header.Set("Authorization", "Bearer "+authResponse.AuthToken)
-		resp, err = c.client.Do(req)
+		resp, err = c.send(req)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	if err := checkResponse(resp); err != nil {
@@ -240,7 +290,7 @@ func (c *Client) Do(req *http.Request, v interface{}) (*http.Response, error) {
 	return resp, err
 }
 
-func (c *Client) refreshTokens() (*AuthResponse, error) {
+func (c *Client) refreshTokens(ctx context.Context) (*AuthResponse, error) {
 	c.AuthToken = ""
 	rr := &RefreshTokenRequest{
 		Login:        c.Login,
@@ -250,6 +300,7 @@ func (c *Client) refreshTokens() (*AuthResponse, error) {
 	if err != nil {
 		return nil, err
 	}
+	refreshRequest = refreshRequest.WithContext(ctx)
 	refreshRequest.Header.Add("X-GOPRSC-Refresh", "1")
 	authResponse := &AuthResponse{}
 	_, err = c.Do(refreshRequest, &authResponse)
@@ -309,3 +360,11 @@ func (e ErrorResponse) Error() string {
 		return fmt.Sprintln("Unknown error")
 	}
 }
+
+// isNotFound reports whether err is an API error caused by a 404 Not Found response.
+func isNotFound(err error) bool {
+	if e, ok := err.(*ErrorResponse); ok && e.Response != nil {
+		return e.Response.StatusCode == http.StatusNotFound
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+// Command goprsc-journal verifies tamper-evident journals written by goprsc clients.
+//
+// Usage:
+//
+//	goprsc-journal verify [-key hex-public-key] journal-file
+//
+// The journal is verified including its rotated files. The command exits with a non-zero
+// status if the hash chain is broken, a checkpoint signature is invalid or, when a key is
+// given, the journal doesn't end with a signed checkpoint.
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/lyubenblagoev/goprsc"
+)
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "verify" {
+		fmt.Fprintln(os.Stderr, "usage: goprsc-journal verify [-key hex-public-key] journal-file")
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	keyHex := fs.String("key", "", "hex encoded Ed25519 public key for checking checkpoint signatures")
+	fs.Parse(os.Args[2:])
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var key ed25519.PublicKey
+	if len(*keyHex) > 0 {
+		b, err := hex.DecodeString(*keyHex)
+		if err != nil || len(b) != ed25519.PublicKeySize {
+			fmt.Fprintln(os.Stderr, "invalid public key")
+			os.Exit(2)
+		}
+		key = ed25519.PublicKey(b)
+	}
+
+	v, err := goprsc.VerifyJournal(fs.Arg(0), key)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("%d records, %d checkpoints, last entry %d, last checkpoint %d\n", v.Records, v.Checkpoints, v.LastSeq, v.LastCheckpoint)
+	if key != nil && !v.Sealed {
+		fmt.Fprintln(os.Stderr, "journal is not sealed with a checkpoint, it may have been truncated")
+		os.Exit(1)
+	}
+}
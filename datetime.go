@@ -1,9 +1,33 @@
 package goprsc
 
-import "time"
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// dateTimeLayout is the format of the times sent by the Postfix REST Server. Fractional
+// seconds are only written if present.
+const dateTimeLayout = "2006-01-02T15:04:05.999999999-0700"
+
+// dateTimeLayouts are the ISO-8601 variants accepted when parsing times. Times without a
+// zone offset are in UTC.
+var dateTimeLayouts = []string{
+	dateTimeLayout,
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999-07",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999-0700",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
 
 // DateTime represents a time that can be unmarshaled from a JSON string
 // formatted as "yyyy-mm-ddThh:mm:ss+|-hhmm" (e.g. '2017-01-02T15:47:59+0100').
+// RFC 3339 times, optionally with fractional seconds, and a few other ISO-8601
+// variants are accepted as well. The zero value is represented as JSON null.
 // All exported methods of time.Time can be called on DateTime.
 type DateTime struct {
 	time.Time
@@ -13,14 +37,87 @@ func (t DateTime) String() string {
 	return t.Time.String()
 }
 
+// ParseDateTime parses s in one of the formats accepted by DateTime. An empty string
+// results in the zero value.
+func ParseDateTime(s string) (DateTime, error) {
+	s = strings.TrimSpace(s)
+	if len(s) == 0 {
+		return DateTime{}, nil
+	}
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return DateTime{t}, nil
+		}
+	}
+	return DateTime{}, fmt.Errorf("invalid date and time %q", s)
+}
+
+// MarshalJSON implements the json.Marshaler interface. The time is written in the format
+// used by the server or as null if it is zero.
+func (t DateTime) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return []byte(`"` + t.Format(dateTimeLayout) + `"`), nil
+}
+
 // UnmarshalJSON implements the json.Unmarshaler interface.
 func (t *DateTime) UnmarshalJSON(data []byte) error {
 	str := string(data)
+	if str == "null" {
+		*t = DateTime{}
+		return nil
+	}
+	if len(str) < 2 || str[0] != '"' || str[len(str)-1] != '"' {
+		return fmt.Errorf("invalid date and time %s", str)
+	}
 	var err error
-	t.Time, err = time.Parse(`"2006-01-02T15:04:05-0700"`, str)
+	*t, err = ParseDateTime(str[1 : len(str)-1])
 	return err
 }
 
+// MarshalText implements the encoding.TextMarshaler interface. The zero time is written
+// as an empty string.
+func (t DateTime) MarshalText() ([]byte, error) {
+	if t.IsZero() {
+		return []byte{}, nil
+	}
+	return []byte(t.Format(dateTimeLayout)), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (t *DateTime) UnmarshalText(data []byte) error {
+	var err error
+	*t, err = ParseDateTime(string(data))
+	return err
+}
+
+// Scan implements the sql.Scanner interface. NULL is scanned as the zero time.
+func (t *DateTime) Scan(src interface{}) error {
+	var err error
+	switch v := src.(type) {
+	case nil:
+		*t = DateTime{}
+	case time.Time:
+		*t = DateTime{v}
+	case string:
+		*t, err = ParseDateTime(v)
+	case []byte:
+		*t, err = ParseDateTime(string(v))
+	default:
+		err = fmt.Errorf("can't scan %T into DateTime", src)
+	}
+	return err
+}
+
+// Value implements the driver.Valuer interface. The zero time is stored as NULL.
+func (t DateTime) Value() (driver.Value, error) {
+	if t.IsZero() {
+		return nil, nil
+	}
+	return t.Time, nil
+}
+
 // Equal reports whether t and u are equal based on time.Equal().
 func (t DateTime) Equal(u DateTime) bool {
 	return t.Time.Equal(u.Time)
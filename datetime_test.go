@@ -24,6 +24,14 @@ func TestDateTime_UnmarshalJSON(t *testing.T) {
 	}{
 		{"Reference", referenceDateTimeStr, DateTime{referenceDateTime}, false, true},
 		{"Mismatch", referenceDateTimeStr, DateTime{}, false, false},
+		{"RFC3339", `"2017-01-02T15:47:59+01:00"`, DateTime{referenceDateTime}, false, true},
+		{"UTC", `"2017-01-02T14:47:59Z"`, DateTime{referenceDateTime}, false, true},
+		{"Fraction", `"2017-01-02T15:47:59.250+0100"`, DateTime{referenceDateTime.Add(250 * time.Millisecond)}, false, true},
+		{"NoZone", `"2017-01-02T14:47:59"`, DateTime{referenceDateTime}, false, true},
+		{"Null", `null`, DateTime{}, false, true},
+		{"Empty", `""`, DateTime{}, false, true},
+		{"Invalid", `"yesterday"`, DateTime{}, true, true},
+		{"Number", `1483368479`, DateTime{}, true, true},
 	}
 	for _, tc := range testCases {
 		var got DateTime
@@ -38,3 +46,91 @@ func TestDateTime_UnmarshalJSON(t *testing.T) {
 		}
 	}
 }
+
+func TestDateTime_MarshalJSON(t *testing.T) {
+	testCases := []struct {
+		desc string
+		data string
+	}{
+		{"Reference", referenceDateTimeStr},
+		{"Fraction", `"2017-01-02T15:47:59.25+0100"`},
+		{"Zero", `null`},
+	}
+	for _, tc := range testCases {
+		var dt DateTime
+		if err := json.Unmarshal([]byte(tc.data), &dt); err != nil {
+			t.Fatalf("%s: %v", tc.desc, err)
+		}
+		data, err := json.Marshal(dt)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.desc, err)
+		}
+		if string(data) != tc.data {
+			t.Errorf("%s: expected: %s, got: %s", tc.desc, tc.data, data)
+		}
+	}
+
+	a := Account{ID: 1, Username: "john", Created: DateTime{referenceDateTime}}
+	data, err := json.Marshal(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b Account
+	if err := json.Unmarshal(data, &b); err != nil {
+		t.Fatal(err)
+	}
+	if !b.Created.Equal(a.Created) || !b.Updated.IsZero() {
+		t.Errorf("account didn't round-trip: %s", data)
+	}
+}
+
+func TestDateTime_Text(t *testing.T) {
+	dt := DateTime{referenceDateTime}
+	text, err := dt.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(text) != "2017-01-02T14:47:59+0000" {
+		t.Errorf("unexpected text: %s", text)
+	}
+	var got DateTime
+	if err := got.UnmarshalText(text); err != nil || !got.Equal(dt) {
+		t.Errorf("text didn't round-trip: %v, %v", got, err)
+	}
+	if text, _ := (DateTime{}).MarshalText(); len(text) != 0 {
+		t.Errorf("expected empty text for zero time, got: %s", text)
+	}
+}
+
+func TestDateTime_Scan(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		src     interface{}
+		want    DateTime
+		wantErr bool
+	}{
+		{"Time", referenceDateTime, DateTime{referenceDateTime}, false},
+		{"String", "2017-01-02 15:47:59+0100", DateTime{referenceDateTime}, false},
+		{"Bytes", []byte("2017-01-02T14:47:59Z"), DateTime{referenceDateTime}, false},
+		{"Null", nil, DateTime{}, false},
+		{"Int", 42, DateTime{}, true},
+	}
+	for _, tc := range testCases {
+		var got DateTime
+		err := got.Scan(tc.src)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("%s: gotErr=%v, wantErr=%v, err=%v", tc.desc, gotErr, tc.wantErr, err)
+			continue
+		}
+		if !got.Equal(tc.want) {
+			t.Errorf("%s: got=%v, want=%v", tc.desc, got, tc.want)
+		}
+	}
+
+	if v, err := (DateTime{}).Value(); v != nil || err != nil {
+		t.Errorf("expected NULL for zero time, got: %v, %v", v, err)
+	}
+	if v, err := (DateTime{referenceDateTime}).Value(); v != referenceDateTime || err != nil {
+		t.Errorf("unexpected value: %v, %v", v, err)
+	}
+}
@@ -0,0 +1,169 @@
+package goprsc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// DiffKind is the kind of a difference between two snapshots.
+type DiffKind string
+
+// Kinds of differences between two snapshots.
+const (
+	DiffOnlyLeft  DiffKind = "only-in-left"
+	DiffOnlyRight DiffKind = "only-in-right"
+	DiffChanged   DiffKind = "changed"
+)
+
+// FieldChange is a change of a single field of an object.
+type FieldChange struct {
+	Field string `json:"field"`
+	Left  string `json:"left"`
+	Right string `json:"right"`
+}
+
+// DiffEntry is a difference of a single object between two snapshots.
+type DiffEntry struct {
+	Kind DiffKind `json:"kind"`
+
+	// Object identifies the object (e.g. "account john@example.com").
+	Object string `json:"object"`
+
+	// Changes lists the changed fields of objects present on both sides.
+	Changes []FieldChange `json:"changes,omitempty"`
+}
+
+// SnapshotDiff is the difference between two snapshots. Server-assigned IDs and
+// timestamps are ignored.
+type SnapshotDiff struct {
+	Entries []DiffEntry `json:"entries"`
+}
+
+// diffRecord is the comparable representation of a snapshot object.
+type diffRecord struct {
+	rank   int
+	object string
+	fields []diffField
+}
+
+type diffField struct {
+	name  string
+	value string
+}
+
+func diffRecords(s *Snapshot) map[string]diffRecord {
+	records := make(map[string]diffRecord)
+	add := func(rank int, object string, fields ...string) {
+		r := diffRecord{rank: rank, object: object}
+		for i := 0; i < len(fields); i += 2 {
+			r.fields = append(r.fields, diffField{name: fields[i], value: fields[i+1]})
+		}
+		records[object] = r
+	}
+
+	for _, d := range s.Domains {
+		add(0, "domain "+normalizeAddress(d.Name), "enabled", strconv.FormatBool(d.Enabled))
+		for _, a := range d.Accounts {
+			address := normalizeAddress(a.Username + "@" + d.Name)
+			add(1, "account "+address, "enabled", strconv.FormatBool(a.Enabled))
+			if a.IncomingBcc != nil {
+				add(3, "incoming bcc "+address, "email", a.IncomingBcc.Email, "enabled", strconv.FormatBool(a.IncomingBcc.Enabled))
+			}
+			if a.OutgoingBcc != nil {
+				add(3, "outgoing bcc "+address, "email", a.OutgoingBcc.Email, "enabled", strconv.FormatBool(a.OutgoingBcc.Enabled))
+			}
+		}
+		for _, a := range d.Aliases {
+			add(2, fmt.Sprintf("alias %s -> %s", normalizeAddress(a.Name+"@"+d.Name), normalizeAddress(a.Email)), "enabled", strconv.FormatBool(a.Enabled))
+		}
+	}
+	return records
+}
+
+// DiffSnapshots compares two snapshots. Entries are ordered by object type (domains,
+// accounts, aliases, BCCs) and name.
+func DiffSnapshots(left, right *Snapshot) *SnapshotDiff {
+	l, r := diffRecords(left), diffRecords(right)
+
+	var records []diffRecord
+	for _, rec := range l {
+		records = append(records, rec)
+	}
+	for k, rec := range r {
+		if _, ok := l[k]; !ok {
+			records = append(records, rec)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].rank != records[j].rank {
+			return records[i].rank < records[j].rank
+		}
+		return records[i].object < records[j].object
+	})
+
+	d := &SnapshotDiff{Entries: []DiffEntry{}}
+	for _, rec := range records {
+		lr, inLeft := l[rec.object]
+		rr, inRight := r[rec.object]
+		switch {
+		case !inRight:
+			d.Entries = append(d.Entries, DiffEntry{Kind: DiffOnlyLeft, Object: rec.object})
+		case !inLeft:
+			d.Entries = append(d.Entries, DiffEntry{Kind: DiffOnlyRight, Object: rec.object})
+		default:
+			var changes []FieldChange
+			for i, f := range lr.fields {
+				if f.value != rr.fields[i].value {
+					changes = append(changes, FieldChange{Field: f.name, Left: f.value, Right: rr.fields[i].value})
+				}
+			}
+			if len(changes) > 0 {
+				d.Entries = append(d.Entries, DiffEntry{Kind: DiffChanged, Object: rec.object, Changes: changes})
+			}
+		}
+	}
+	return d
+}
+
+// Diff reads the state of both servers and compares it.
+func Diff(left, right *Client) (*SnapshotDiff, error) {
+	ls, err := left.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	rs, err := right.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return DiffSnapshots(ls, rs), nil
+}
+
+// Empty reports whether there are no differences.
+func (d *SnapshotDiff) Empty() bool {
+	return len(d.Entries) == 0
+}
+
+// WriteUnified writes the differences in a unified diff like format. Objects only in the
+// left snapshot are prefixed with '-', objects only in the right snapshot with '+' and
+// changed fields are listed below the object they belong to.
+func (d *SnapshotDiff) WriteUnified(w io.Writer, leftName, rightName string) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "--- %s\n+++ %s\n", leftName, rightName)
+	for _, e := range d.Entries {
+		switch e.Kind {
+		case DiffOnlyLeft:
+			fmt.Fprintf(bw, "-%s\n", e.Object)
+		case DiffOnlyRight:
+			fmt.Fprintf(bw, "+%s\n", e.Object)
+		case DiffChanged:
+			fmt.Fprintf(bw, " %s\n", e.Object)
+			for _, c := range e.Changes {
+				fmt.Fprintf(bw, "-\t%s: %s\n+\t%s: %s\n", c.Field, c.Left, c.Field, c.Right)
+			}
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,86 @@
+package goprsc
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	left := &Snapshot{Domains: []DomainSnapshot{
+		{
+			Domain: Domain{ID: 1, Name: "example.com", Enabled: true},
+			Accounts: []AccountSnapshot{
+				{Account: Account{ID: 1, Username: "john", Enabled: true}, IncomingBcc: &Bcc{ID: 1, Email: "archive@example.com", Enabled: true}},
+				{Account: Account{ID: 2, Username: "jane", Enabled: true}},
+			},
+			Aliases: []Alias{{ID: 1, Name: "team", Email: "john@example.com", Enabled: true}},
+		},
+		{Domain: Domain{ID: 2, Name: "old.example.com", Enabled: true}},
+	}}
+	right := &Snapshot{Domains: []DomainSnapshot{
+		{
+			Domain: Domain{ID: 7, Name: "example.com", Enabled: true},
+			Accounts: []AccountSnapshot{
+				{Account: Account{ID: 9, Username: "john", Enabled: false}, IncomingBcc: &Bcc{ID: 3, Email: "backup@example.com", Enabled: true}},
+				{Account: Account{ID: 8, Username: "jane", Enabled: true}},
+			},
+			Aliases: []Alias{
+				{ID: 5, Name: "team", Email: "john@example.com", Enabled: true},
+				{ID: 6, Name: "team", Email: "jane@example.com", Enabled: true},
+			},
+		},
+	}}
+
+	d := DiffSnapshots(left, right)
+	expected := []DiffEntry{
+		{Kind: DiffOnlyLeft, Object: "domain old.example.com"},
+		{Kind: DiffChanged, Object: "account john@example.com", Changes: []FieldChange{{Field: "enabled", Left: "true", Right: "false"}}},
+		{Kind: DiffOnlyRight, Object: "alias team@example.com -> jane@example.com"},
+		{Kind: DiffChanged, Object: "incoming bcc john@example.com", Changes: []FieldChange{{Field: "email", Left: "archive@example.com", Right: "backup@example.com"}}},
+	}
+	if !reflect.DeepEqual(d.Entries, expected) {
+		t.Fatalf("expected: %#v, got: %#v", expected, d.Entries)
+	}
+
+	var buf bytes.Buffer
+	if err := d.WriteUnified(&buf, "old", "new"); err != nil {
+		t.Fatal(err)
+	}
+	want := `--- old
++++ new
+-domain old.example.com
+ account john@example.com
+-	enabled: true
++	enabled: false
++alias team@example.com -> jane@example.com
+ incoming bcc john@example.com
+-	email: archive@example.com
++	email: backup@example.com
+`
+	if buf.String() != want {
+		t.Fatalf("expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+
+	if !DiffSnapshots(left, left).Empty() {
+		t.Fatal("expected no differences")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	fs := setupFake()
+	defer shutdown()
+	fs.addDomain("example.com", true)
+
+	other, right, closeOther := startFake()
+	defer closeOther()
+	other.addDomain("example.org", true)
+
+	d, err := Diff(client, right)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Entries) != 2 || d.Entries[0].Kind != DiffOnlyLeft || d.Entries[1].Kind != DiffOnlyRight {
+		t.Fatalf("unexpected entries: %#v", d.Entries)
+	}
+}
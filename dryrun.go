@@ -0,0 +1,166 @@
+package goprsc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// PlannedOperation is a mutation recorded by a client in dry-run mode instead of being
+// sent to the server.
+type PlannedOperation struct {
+	// Operation is "create", "update" or "delete".
+	Operation string `json:"operation"`
+
+	Method string         `json:"method"`
+	Path   string         `json:"path"`
+	Target MutationTarget `json:"target"`
+
+	// Request is the request body with passwords and tokens redacted.
+	Request json.RawMessage `json:"request,omitempty"`
+
+	// Error is the validation error of the request, if any.
+	Error string `json:"error,omitempty"`
+}
+
+func (op PlannedOperation) String() string {
+	s := fmt.Sprintf("%s %s", op.Method, op.Path)
+	if len(op.Request) > 0 {
+		s += " " + string(op.Request)
+	}
+	if len(op.Error) > 0 {
+		s += " (invalid: " + op.Error + ")"
+	}
+	return s
+}
+
+// dryRun holds the operations recorded by a client in dry-run mode.
+type dryRun struct {
+	mu         sync.Mutex
+	operations []PlannedOperation
+}
+
+// DryRunOption is a client option enabling the dry-run mode. GET requests are sent to the
+// server as usual, while POST, PUT and DELETE requests are validated and recorded as
+// planned operations without being sent. Mutation observers are not notified about them.
+func DryRunOption() ClientOption {
+	return func(c *Client) error {
+		c.dryRun = &dryRun{}
+		return nil
+	}
+}
+
+// PlannedOperations returns the operations recorded in dry-run mode.
+func (c *Client) PlannedOperations() []PlannedOperation {
+	if c.dryRun == nil {
+		return nil
+	}
+	c.dryRun.mu.Lock()
+	defer c.dryRun.mu.Unlock()
+	return append([]PlannedOperation{}, c.dryRun.operations...)
+}
+
+// ResetPlannedOperations discards the operations recorded in dry-run mode.
+func (c *Client) ResetPlannedOperations() {
+	if c.dryRun == nil {
+		return
+	}
+	c.dryRun.mu.Lock()
+	defer c.dryRun.mu.Unlock()
+	c.dryRun.operations = nil
+}
+
+// plan records the mutation req and returns a synthetic successful response. The
+// returned error is the validation error of the request.
+func (d *dryRun) plan(m *Mutation, req *http.Request) (*http.Response, error) {
+	target, ok := m.Target()
+	op := PlannedOperation{
+		Operation: m.Operation(),
+		Method:    m.Method,
+		Path:      m.Path,
+		Target:    target,
+		Request:   redactBody(m.Body),
+	}
+
+	var err error
+	if !ok {
+		err = fmt.Errorf("unknown API path %s", m.Path)
+	} else {
+		err = validateMutation(m, target)
+	}
+	if err != nil {
+		op.Error = err.Error()
+	}
+
+	d.mu.Lock()
+	d.operations = append(d.operations, op)
+	d.mu.Unlock()
+
+	resp := &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+		Request:    req,
+	}
+	return resp, err
+}
+
+// validateMutation checks that the request body carries the fields required by the API.
+func validateMutation(m *Mutation, t MutationTarget) error {
+	if m.Method == http.MethodDelete {
+		return nil
+	}
+
+	var body struct {
+		Name            string `json:"name"`
+		Username        string `json:"username"`
+		Email           string `json:"email"`
+		Password        string `json:"password"`
+		ConfirmPassword string `json:"confirmPassword"`
+	}
+	if err := json.Unmarshal(m.Body, &body); err != nil {
+		return fmt.Errorf("invalid request body: %v", err)
+	}
+
+	create := m.Method == http.MethodPost
+	switch t.Object {
+	case ObjectDomain:
+		if create && len(body.Name) == 0 {
+			return errors.New("domain name is required")
+		}
+	case ObjectAccount:
+		if create && len(body.Username) == 0 {
+			return errors.New("username is required")
+		}
+		if create && len(body.Password) == 0 {
+			return errors.New("password is required")
+		}
+		if body.Password != body.ConfirmPassword {
+			return errors.New("password and confirmation don't match")
+		}
+	case ObjectAlias:
+		if create && len(body.Name) == 0 {
+			return errors.New("alias name is required")
+		}
+		if (create || len(body.Email) > 0) && !strings.Contains(body.Email, "@") {
+			return fmt.Errorf("invalid alias email %q", body.Email)
+		}
+	case ObjectBcc:
+		if t.BccType != IncomingBccType && t.BccType != OutgoingBccType {
+			return fmt.Errorf("unknown bcc type %q", t.BccType)
+		}
+		if (create || len(body.Email) > 0) && !strings.Contains(body.Email, "@") {
+			return fmt.Errorf("invalid bcc email %q", body.Email)
+		}
+	}
+	return nil
+}
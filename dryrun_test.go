@@ -0,0 +1,65 @@
+package goprsc
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestClient_DryRun(t *testing.T) {
+	fs := setupFake()
+	defer shutdown()
+	fs.addDomain("example.com", true)
+
+	if err := DryRunOption()(client); err != nil {
+		t.Fatal(err)
+	}
+
+	domains, err := client.Domains.List()
+	if err != nil || len(domains) != 1 {
+		t.Fatalf("expected GET requests to be sent, got: %v, %v", domains, err)
+	}
+	if err := client.Domains.Create("example.org"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Accounts.Create("example.com", "john", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Aliases.Create("example.com", "team", "invalid"); err == nil {
+		t.Fatal("expected invalid alias to fail validation")
+	}
+	if err := client.Domains.Delete("example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range fs.requests {
+		if !strings.HasPrefix(r, http.MethodGet) {
+			t.Errorf("unexpected request sent in dry-run mode: %s", r)
+		}
+	}
+	if fs.domain("example.org") != nil || fs.domain("example.com") == nil {
+		t.Fatal("server state was changed in dry-run mode")
+	}
+
+	ops := client.PlannedOperations()
+	if len(ops) != 4 {
+		t.Fatalf("expected 4 planned operations, got: %v", ops)
+	}
+	expected := []string{"create", "create", "create", "delete"}
+	for i, op := range ops {
+		if op.Operation != expected[i] {
+			t.Errorf("%d: expected: %s, got: %s", i, expected[i], op.Operation)
+		}
+	}
+	if strings.Contains(string(ops[1].Request), "secret") {
+		t.Errorf("password was not redacted: %s", ops[1].Request)
+	}
+	if len(ops[2].Error) == 0 || ops[2].Target.Alias != "team" {
+		t.Errorf("unexpected invalid operation: %#v", ops[2])
+	}
+
+	client.ResetPlannedOperations()
+	if len(client.PlannedOperations()) != 0 {
+		t.Fatal("expected planned operations to be reset")
+	}
+}
@@ -0,0 +1,288 @@
+package goprsc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AccountExpiry is the expiry of a temporary account.
+type AccountExpiry struct {
+	Domain   string    `json:"domain"`
+	Username string    `json:"username"`
+	Expires  time.Time `json:"expires"`
+
+	// Notified is set when the pre-expiry notice was sent.
+	Notified bool `json:"notified,omitempty"`
+
+	// Disabled is the time the account was disabled by a Reaper or zero.
+	Disabled time.Time `json:"disabled"`
+}
+
+// Address returns the email address of the account.
+func (e *AccountExpiry) Address() string {
+	return normalizeAddress(e.Username + "@" + e.Domain)
+}
+
+// ExpiryStore is a JSON file recording the expiries of temporary accounts. The file is
+// read on every access, so several stores, e.g. of the client creating temporary accounts
+// and of a Reaper, can share it.
+type ExpiryStore struct {
+	// Path is the path of the store file.
+	Path string
+
+	mu sync.Mutex
+}
+
+// CreateTemporary creates an account which expires ttl after creation and records its
+// expiry in store. If the expiry can't be recorded, the account is deleted again.
+func (s *AccountService) CreateTemporary(domain, username, password string, store *ExpiryStore, ttl time.Duration) error {
+	expires := time.Now().Add(ttl)
+	if err := s.Create(domain, username, password); err != nil {
+		return err
+	}
+	if err := store.Set(domain, username, expires); err != nil {
+		if derr := s.Delete(domain, username); derr != nil {
+			return fmt.Errorf("record expiry of %s@%s: %v (delete failed: %v)", username, domain, err, derr)
+		}
+		return fmt.Errorf("record expiry of %s@%s: %v", username, domain, err)
+	}
+	return nil
+}
+
+// Set records that the account expires at the given time. The notice and disabled state
+// of an existing expiry are reset, but an account disabled by a Reaper isn't re-enabled.
+func (s *ExpiryStore) Set(domain, username string, expires time.Time) error {
+	return s.update(func(entries map[string]*AccountExpiry) {
+		e := &AccountExpiry{Domain: domain, Username: username, Expires: expires.UTC()}
+		entries[e.Address()] = e
+	})
+}
+
+// Remove removes the expiry of the account.
+func (s *ExpiryStore) Remove(domain, username string) error {
+	return s.update(func(entries map[string]*AccountExpiry) {
+		delete(entries, normalizeAddress(username+"@"+domain))
+	})
+}
+
+// Get returns the expiry of the account or nil if it doesn't expire.
+func (s *ExpiryStore) Get(domain, username string) (*AccountExpiry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return entries[normalizeAddress(username+"@"+domain)], nil
+}
+
+// List returns all recorded expiries ordered by expiry time.
+func (s *ExpiryStore) List() ([]AccountExpiry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return sortedExpiries(entries), nil
+}
+
+func sortedExpiries(entries map[string]*AccountExpiry) []AccountExpiry {
+	list := make([]AccountExpiry, 0, len(entries))
+	for _, e := range entries {
+		list = append(list, *e)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].Expires.Equal(list[j].Expires) {
+			return list[i].Expires.Before(list[j].Expires)
+		}
+		return list[i].Address() < list[j].Address()
+	})
+	return list
+}
+
+// update applies fn to the entries freshly read from the file and writes them back.
+func (s *ExpiryStore) update(fn func(entries map[string]*AccountExpiry)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries, err := s.load()
+	if err != nil {
+		return err
+	}
+	fn(entries)
+	data, err := json.MarshalIndent(sortedExpiries(entries), "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.Path, data, 0600)
+}
+
+// load reads the entries from the file, keyed by address.
+func (s *ExpiryStore) load() (map[string]*AccountExpiry, error) {
+	entries := make(map[string]*AccountExpiry)
+	data, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return entries, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var list []AccountExpiry
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("expiry store %s: %v", s.Path, err)
+	}
+	for i := range list {
+		entries[list[i].Address()] = &list[i]
+	}
+	return entries, nil
+}
+
+// ExpiryNotice is sent by a Reaper before a temporary account expires.
+type ExpiryNotice struct {
+	Domain   string
+	Username string
+	Expires  time.Time
+}
+
+// ReapResult describes the accounts handled by a Reaper pass.
+type ReapResult struct {
+	Notified []string
+	Disabled []string
+	Deleted  []string
+
+	// Removed lists the expired accounts which no longer existed on the server.
+	Removed []string
+}
+
+// Reaper disables temporary accounts when they expire and optionally deletes them after a
+// grace period.
+type Reaper struct {
+	// Client is the client used to disable and delete accounts.
+	Client *Client
+
+	// Store holds the expiries of the temporary accounts.
+	Store *ExpiryStore
+
+	// Interval is the time between two passes (defaults to one hour).
+	Interval time.Duration
+
+	// GracePeriod is the time after expiry after which accounts are deleted. Zero
+	// disables deletion.
+	GracePeriod time.Duration
+
+	// NoticePeriod is the time before expiry at which NoticeHandler is called.
+	NoticePeriod time.Duration
+
+	// NoticeHandler, if set, is called once for each account NoticePeriod before it
+	// expires.
+	NoticeHandler func(n ExpiryNotice)
+
+	// ErrorHandler, if set, is called with the errors from the periodic passes.
+	ErrorHandler func(err error)
+
+	// now returns the current time. It is replaced in tests.
+	now func() time.Time
+}
+
+// Run reaps expired accounts every Interval until ctx is done. Run returns ctx.Err()
+// when stopped.
+func (r *Reaper) Run(ctx context.Context) error {
+	interval := r.Interval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.Reap(); err != nil && r.ErrorHandler != nil {
+			r.ErrorHandler(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reap sends the due pre-expiry notices, disables expired accounts and deletes the ones
+// whose grace period has passed. A failure for one account doesn't stop the handling of
+// the others; the returned error lists all failures.
+func (r *Reaper) Reap() (*ReapResult, error) {
+	now := time.Now()
+	if r.now != nil {
+		now = r.now()
+	}
+	entries, err := r.Store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReapResult{}
+	var errs []string
+	for i := range entries {
+		e := &entries[i]
+		if err := r.reap(e, now, result); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", e.Address(), err))
+		}
+	}
+	if len(errs) > 0 {
+		return result, fmt.Errorf("reap failed: %s", strings.Join(errs, "; "))
+	}
+	return result, nil
+}
+
+func (r *Reaper) reap(e *AccountExpiry, now time.Time, result *ReapResult) error {
+	address := e.Address()
+	switch {
+	case r.GracePeriod > 0 && !now.Before(e.Expires.Add(r.GracePeriod)):
+		deleteErr := r.Client.Accounts.Delete(e.Domain, e.Username)
+		if deleteErr != nil && !isNotFound(deleteErr) {
+			return deleteErr
+		}
+		if err := r.Store.Remove(e.Domain, e.Username); err != nil {
+			return err
+		}
+		if deleteErr != nil {
+			result.Removed = append(result.Removed, address)
+		} else {
+			result.Deleted = append(result.Deleted, address)
+		}
+
+	case !now.Before(e.Expires) && e.Disabled.IsZero():
+		err := r.Client.Accounts.Update(e.Domain, e.Username, &AccountUpdateRequest{Enabled: false})
+		if isNotFound(err) {
+			result.Removed = append(result.Removed, address)
+			return r.Store.Remove(e.Domain, e.Username)
+		}
+		if err != nil {
+			return err
+		}
+		result.Disabled = append(result.Disabled, address)
+		return r.Store.update(func(entries map[string]*AccountExpiry) {
+			if s, ok := entries[address]; ok {
+				s.Disabled = now.UTC()
+			}
+		})
+
+	case r.NoticePeriod > 0 && !e.Notified && !now.Before(e.Expires.Add(-r.NoticePeriod)) && now.Before(e.Expires):
+		if r.NoticeHandler != nil {
+			r.NoticeHandler(ExpiryNotice{Domain: e.Domain, Username: e.Username, Expires: e.Expires})
+		}
+		result.Notified = append(result.Notified, address)
+		return r.Store.update(func(entries map[string]*AccountExpiry) {
+			if s, ok := entries[address]; ok {
+				s.Notified = true
+			}
+		})
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package goprsc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReaper_Reap(t *testing.T) {
+	fs := setupFake()
+	defer shutdown()
+	fs.addDomain("example.com", true)
+
+	dir, err := ioutil.TempDir("", "goprsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := &ExpiryStore{Path: filepath.Join(dir, "expiry.json")}
+	for _, username := range []string{"intern", "gone"} {
+		if err := client.Accounts.CreateTemporary("example.com", username, "secret", store, 30*24*time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Accounts created with Create are permanent.
+	if err := client.Accounts.Create("example.com", "john", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if e, err := store.Get("example.com", "john"); err != nil || e != nil {
+		t.Fatalf("expected no expiry for a permanent account, got: %v, %v", e, err)
+	}
+
+	e, err := store.Get("example.com", "intern")
+	if err != nil || e == nil {
+		t.Fatalf("expected the created account to expire, got: %v, %v", e, err)
+	}
+	if d := time.Until(e.Expires); d < 29*24*time.Hour || d > 30*24*time.Hour {
+		t.Fatalf("unexpected expiry: %v", e.Expires)
+	}
+
+	expires := time.Date(2017, 2, 1, 0, 0, 0, 0, time.UTC)
+	if err := store.Set("example.com", "intern", expires); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("example.com", "gone", expires); err != nil {
+		t.Fatal(err)
+	}
+
+	now := expires.Add(-48 * time.Hour)
+	var notices []ExpiryNotice
+	r := &Reaper{
+		Client:        client,
+		Store:         &ExpiryStore{Path: store.Path},
+		GracePeriod:   7 * 24 * time.Hour,
+		NoticePeriod:  3 * 24 * time.Hour,
+		NoticeHandler: func(n ExpiryNotice) { notices = append(notices, n) },
+		now:           func() time.Time { return now },
+	}
+
+	for i := 0; i < 2; i++ {
+		result, err := r.Reap()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 && len(result.Notified) != 2 || i == 1 && len(result.Notified) != 0 {
+			t.Fatalf("%d: unexpected result: %#v", i, result)
+		}
+	}
+	if len(notices) != 2 || notices[0].Username != "gone" || !notices[0].Expires.Equal(expires) {
+		t.Fatalf("unexpected notices: %#v", notices)
+	}
+
+	fs.mu.Lock()
+	fs.accounts["example.com"] = append(fs.accounts["example.com"][:1], fs.accounts["example.com"][2])
+	fs.mu.Unlock()
+
+	now = expires
+	result, err := r.Reap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Disabled) != 1 || result.Disabled[0] != "intern@example.com" || len(result.Removed) != 1 {
+		t.Fatalf("unexpected result: %#v", result)
+	}
+	if fs.account("example.com", "intern").Enabled {
+		t.Fatal("expected the expired account to be disabled")
+	}
+
+	now = expires.Add(7 * 24 * time.Hour)
+	if result, err = r.Reap(); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Deleted) != 1 || fs.account("example.com", "intern") != nil {
+		t.Fatalf("expected the account to be deleted after the grace period, got: %#v", result)
+	}
+	if list, err := r.Store.List(); err != nil || len(list) != 0 {
+		t.Fatalf("expected empty store, got: %v, %v", list, err)
+	}
+}
+
+func TestExpiryStore_Shared(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goprsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "expiry.json")
+	creator, reaper := &ExpiryStore{Path: path}, &ExpiryStore{Path: path}
+	expires := time.Date(2017, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	if err := creator.Set("example.com", "intern", expires); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reaper.List(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Changes of one store are seen by the other and not overwritten by its writes.
+	if err := reaper.update(func(entries map[string]*AccountExpiry) {
+		entries["intern@example.com"].Notified = true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := creator.Set("example.com", "temp", expires); err != nil {
+		t.Fatal(err)
+	}
+	list, err := reaper.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || !list[0].Notified {
+		t.Fatalf("unexpected expiries: %#v", list)
+	}
+
+	if err := reaper.Remove("example.com", "intern"); err != nil {
+		t.Fatal(err)
+	}
+	if err := creator.Set("example.com", "other", expires); err != nil {
+		t.Fatal(err)
+	}
+	if e, err := creator.Get("example.com", "intern"); err != nil || e != nil {
+		t.Fatalf("expected removed expiry to stay removed, got: %v, %v", e, err)
+	}
+}
@@ -0,0 +1,451 @@
+package goprsc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const refreshTokenURL = authURL + "/refresh-token"
+
+// EndpointStatus describes an endpoint of a client with several endpoints.
+type EndpointStatus struct {
+	// URL is the base URL of the endpoint.
+	URL string
+
+	// Healthy reports whether the last request or health check succeeded.
+	Healthy bool
+
+	// Primary reports whether writes are sent to the endpoint.
+	Primary bool
+
+	// Authenticated reports whether the client holds tokens issued by the endpoint.
+	Authenticated bool
+
+	// LastError is the error of the last failed request or health check.
+	LastError error
+
+	// LastChecked is the time of the last health check.
+	LastChecked time.Time
+}
+
+type endpoint struct {
+	url     *url.URL
+	healthy bool
+	lastErr error
+	checked time.Time
+
+	// authToken and refreshToken were issued by the endpoint. They are used instead of
+	// the tokens of the client, which may have been issued by another endpoint.
+	authToken    string
+	refreshToken string
+}
+
+// endpointPool selects the endpoints requests are sent to.
+type endpointPool struct {
+	mu        sync.Mutex
+	endpoints []*endpoint
+	primary   int
+	next      int
+}
+
+// EndpointsOption is a client option for sending requests to several servers sharing the
+// same data. Each endpoint is a base URL such as "https://mail1.example.com:8443".
+//
+// Writes are sent to a primary endpoint, initially the first one, which is kept until it
+// fails. Reads are distributed over the healthy endpoints. A request failing with a
+// network error or a 502, 503 or 504 response is retried on the next endpoint, healthy
+// endpoints first. Note that a write failing with a network error may have been applied
+// before it is retried.
+//
+// Tokens returned by logins and token refreshes are kept per endpoint, and a request
+// rejected by an endpoint is retried after refreshing the tokens of that endpoint. If the
+// servers don't accept each other's tokens, log in with AuthenticateEndpoints.
+func EndpointsOption(endpoints ...string) ClientOption {
+	return func(c *Client) error {
+		if len(endpoints) == 0 {
+			return errors.New("no endpoints given")
+		}
+		p := &endpointPool{}
+		for _, s := range endpoints {
+			u, err := url.Parse(s)
+			if err != nil {
+				return err
+			}
+			if (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 || strings.Trim(u.Path, "/") != "" {
+				return fmt.Errorf("invalid endpoint %q", s)
+			}
+			p.endpoints = append(p.endpoints, &endpoint{url: &url.URL{Scheme: u.Scheme, Host: u.Host}, healthy: true})
+		}
+
+		first := p.endpoints[0].url
+		c.Protocol = first.Scheme
+		c.Host = first.Hostname()
+		c.Port = first.Port()
+		if len(c.Port) == 0 {
+			c.Port = "80"
+			if first.Scheme == "https" {
+				c.Port = "443"
+			}
+		}
+		c.endpoints = p
+		return nil
+	}
+}
+
+// transmit sends req to the server or, with several endpoints, to the selected endpoint.
+func (c *Client) transmit(req *http.Request) (*http.Response, error) {
+	if c.endpoints == nil {
+		return c.client.Do(req)
+	}
+	return c.endpoints.send(c, req)
+}
+
+// endpointKey is the context key of the endpoint a request is pinned to.
+type endpointKey struct{}
+
+// pinEndpoint returns req bound to the endpoint which sent resp. Pinned requests are sent
+// to that endpoint only.
+func (c *Client) pinEndpoint(req *http.Request, resp *http.Response) *http.Request {
+	if c.endpoints == nil || resp.Request == nil {
+		return req
+	}
+	for _, e := range c.endpoints.endpoints {
+		if e.url.Host == resp.Request.URL.Host {
+			return req.WithContext(context.WithValue(req.Context(), endpointKey{}, e))
+		}
+	}
+	return req
+}
+
+// Endpoints returns the status of the endpoints of the client, or nil if the client was
+// not configured with EndpointsOption.
+func (c *Client) Endpoints() []EndpointStatus {
+	if c.endpoints == nil {
+		return nil
+	}
+	return c.endpoints.status()
+}
+
+// CheckEndpoints checks the health of all endpoints and returns their status. If the
+// primary endpoint is unhealthy, the first healthy endpoint becomes the primary.
+func (c *Client) CheckEndpoints() []EndpointStatus {
+	p := c.endpoints
+	if p == nil {
+		return nil
+	}
+	for _, e := range p.endpoints {
+		err := p.check(c, e)
+		p.mu.Lock()
+		e.healthy, e.checked = err == nil, time.Now()
+		if err != nil {
+			e.lastErr = err
+		}
+		p.mu.Unlock()
+	}
+
+	p.mu.Lock()
+	if !p.endpoints[p.primary].healthy {
+		for i, e := range p.endpoints {
+			if e.healthy {
+				p.primary = i
+				break
+			}
+		}
+	}
+	p.mu.Unlock()
+	return p.status()
+}
+
+// RunHealthChecks checks the health of the endpoints every interval until the context is
+// done, which it returns the error of.
+func (c *Client) RunHealthChecks(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		c.CheckEndpoints()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// AuthenticateEndpoints logs in to each endpoint and keeps the tokens issued by each of
+// them. The tokens of the primary endpoint become the tokens of the client. Endpoints
+// which can't be logged in to are reported in the returned error; the others are still
+// authenticated.
+func (c *Client) AuthenticateEndpoints(login, password string) error {
+	p := c.endpoints
+	if p == nil {
+		return errors.New("client has no endpoints")
+	}
+	var failed []string
+	for _, e := range p.endpoints {
+		req, err := c.NewRequest(http.MethodPost, loginURL, &LoginRequest{Login: login, Password: password})
+		if err != nil {
+			return err
+		}
+		resp, err := p.sendTo(c, e, req)
+		if err == nil {
+			err = checkResponse(resp)
+			resp.Body.Close()
+		}
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", e.url, err))
+		}
+	}
+
+	p.mu.Lock()
+	primary := p.endpoints[p.primary]
+	c.Login = login
+	c.AuthToken, c.RefreshToken = primary.authToken, primary.refreshToken
+	p.mu.Unlock()
+
+	if len(failed) > 0 {
+		return fmt.Errorf("login failed: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+func (p *endpointPool) status() []EndpointStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	status := make([]EndpointStatus, len(p.endpoints))
+	for i, e := range p.endpoints {
+		status[i] = EndpointStatus{
+			URL:           e.url.String(),
+			Healthy:       e.healthy,
+			Primary:       i == p.primary,
+			Authenticated: len(e.authToken) > 0,
+			LastError:     e.lastErr,
+			LastChecked:   e.checked,
+		}
+	}
+	return status
+}
+
+// check returns an error if the endpoint can't be reached or fails with a server error.
+func (p *endpointPool) check(c *Client, e *endpoint) error {
+	req, err := c.NewRequest(http.MethodGet, domainsURL, nil)
+	if err != nil {
+		return err
+	}
+	r, err := p.request(e, req)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(r)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("server error: %s", resp.Status)
+	}
+	return nil
+}
+
+// candidates returns the endpoints to try in order, starting at the primary endpoint for
+// writes or at the next healthy endpoint for reads. Healthy endpoints come first.
+func (p *endpointPool) candidates(write bool) []*endpoint {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n := len(p.endpoints)
+	start := p.primary
+	if !write {
+		start = p.next
+		for i := 0; i < n; i++ {
+			if e := p.endpoints[(p.next+i)%n]; e.healthy {
+				start = (p.next + i) % n
+				break
+			}
+		}
+		p.next = (start + 1) % n
+	}
+
+	var healthy, unhealthy []*endpoint
+	for i := 0; i < n; i++ {
+		e := p.endpoints[(start+i)%n]
+		if e.healthy {
+			healthy = append(healthy, e)
+		} else {
+			unhealthy = append(unhealthy, e)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+// send sends req to the candidate endpoints until one of them doesn't fail. A request
+// pinned to an endpoint is sent to that endpoint only.
+func (p *endpointPool) send(c *Client, req *http.Request) (*http.Response, error) {
+	write := req.Method != http.MethodGet && req.Method != http.MethodHead
+	resendable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	candidates := p.candidates(write)
+	if e, ok := req.Context().Value(endpointKey{}).(*endpoint); ok {
+		candidates = []*endpoint{e}
+	}
+
+	var resp *http.Response
+	var err error
+	for i, e := range candidates {
+		resp, err = p.sendTo(c, e, req)
+		if req.Context().Err() != nil {
+			return resp, err
+		}
+		if !failedOver(resp, err) {
+			p.succeeded(e, write)
+			return resp, err
+		}
+		p.failed(e, resp, err)
+		if i == len(candidates)-1 || !resendable {
+			break
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+	}
+	return resp, err
+}
+
+// failedOver reports whether a request is retried on another endpoint.
+func failedOver(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func (p *endpointPool) succeeded(e *endpoint, write bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e.healthy = true
+	if write {
+		for i := range p.endpoints {
+			if p.endpoints[i] == e {
+				p.primary = i
+			}
+		}
+	}
+}
+
+func (p *endpointPool) failed(e *endpoint, resp *http.Response, err error) {
+	if err == nil {
+		err = fmt.Errorf("server error: %s", resp.Status)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e.healthy, e.lastErr = false, err
+}
+
+// sendTo sends req to the endpoint. A request rejected as unauthorized is retried after
+// refreshing the tokens of the endpoint. Tokens issued by the endpoint are kept.
+func (p *endpointPool) sendTo(c *Client, e *endpoint, req *http.Request) (*http.Response, error) {
+	r, err := p.request(e, req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(r)
+	if err != nil {
+		return nil, err
+	}
+
+	path := apiPath(req)
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized && !strings.HasPrefix(path, authURL+"/"):
+		if !p.refresh(c, e) {
+			return resp, nil
+		}
+		resp.Body.Close()
+		if r, err = p.request(e, req); err != nil {
+			return nil, err
+		}
+		return c.client.Do(r)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+	case path == loginURL || path == refreshTokenURL:
+		data, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+		var ar AuthResponse
+		if json.Unmarshal(data, &ar) == nil && len(ar.AuthToken) > 0 {
+			p.setTokens(e, ar.AuthToken, ar.RefreshToken)
+		}
+	case path == logoutURL:
+		p.setTokens(e, "", "")
+	}
+	return resp, nil
+}
+
+// refresh refreshes the tokens of the endpoint and reports whether it succeeded. The
+// tokens are discarded if the endpoint rejects them.
+func (p *endpointPool) refresh(c *Client, e *endpoint) bool {
+	p.mu.Lock()
+	refreshToken := e.refreshToken
+	p.mu.Unlock()
+	if len(refreshToken) == 0 {
+		return false
+	}
+
+	rr := &RefreshTokenRequest{Login: c.Login, RefreshToken: refreshToken}
+	req, err := c.NewRequest(http.MethodPost, refreshTokenURL, rr)
+	if err != nil {
+		return false
+	}
+	req.Header.Del("Authorization")
+	resp, err := p.sendTo(c, e, req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		p.setTokens(e, "", "")
+		return false
+	}
+	return true
+}
+
+func (p *endpointPool) setTokens(e *endpoint, authToken, refreshToken string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e.authToken, e.refreshToken = authToken, refreshToken
+}
+
+// request returns a copy of req addressed to the endpoint, with a fresh body and the
+// authentication token of the endpoint, if any.
+func (p *endpointPool) request(e *endpoint, req *http.Request) (*http.Request, error) {
+	r := req.Clone(req.Context())
+	r.URL.Scheme, r.URL.Host = e.url.Scheme, e.url.Host
+	r.Host = ""
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+
+	p.mu.Lock()
+	token := e.authToken
+	p.mu.Unlock()
+	if path := apiPath(req); len(token) > 0 && path != loginURL && path != refreshTokenURL {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r, nil
+}
@@ -0,0 +1,271 @@
+package goprsc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// failoverNode is a fake server which can be taken down and, if auth is set, issues its
+// own tokens and rejects requests with other tokens.
+type failoverNode struct {
+	name string
+	fs   *fakeServer
+	url  string
+
+	mu       sync.Mutex
+	down     bool
+	auth     bool
+	token    string
+	refresh  int
+	requests []string
+}
+
+func startFailoverNode(name string) (*failoverNode, func()) {
+	n := &failoverNode{name: name, fs: newFakeServer()}
+	n.fs.addDomain("example.com", true)
+	s := httptest.NewServer(n)
+	n.url = s.URL
+	return n, s.Close
+}
+
+func (n *failoverNode) setDown(down bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.down = down
+}
+
+func (n *failoverNode) log() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]string(nil), n.requests...)
+}
+
+func (n *failoverNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n.mu.Lock()
+	n.requests = append(n.requests, r.Method+" "+r.URL.Path+" "+r.Header.Get("Authorization"))
+	down, auth := n.down, n.auth
+	n.mu.Unlock()
+
+	if down {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if !auth {
+		n.fs.ServeHTTP(w, r)
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	switch r.URL.Path {
+	case "/api/v1/" + loginURL:
+		n.issue(w)
+	case "/api/v1/" + refreshTokenURL:
+		var rr RefreshTokenRequest
+		json.NewDecoder(r.Body).Decode(&rr)
+		if rr.RefreshToken != n.name+"-refresh" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		n.refresh++
+		n.issue(w)
+	default:
+		if r.Header.Get("Authorization") != "Bearer "+n.token || len(n.token) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		n.fs.ServeHTTP(w, r)
+	}
+}
+
+func (n *failoverNode) issue(w http.ResponseWriter) {
+	n.token = n.name + "-token"
+	json.NewEncoder(w).Encode(&AuthResponse{AuthToken: n.token, RefreshToken: n.name + "-refresh"})
+}
+
+func TestEndpointsOption_Invalid(t *testing.T) {
+	cases := [][]string{
+		nil,
+		{"localhost:8080"},
+		{"ftp://localhost"},
+		{"http://localhost/api"},
+	}
+	for _, endpoints := range cases {
+		if _, err := NewClientWithOptions(nil, EndpointsOption(endpoints...)); err == nil {
+			t.Errorf("expected error for %v", endpoints)
+		}
+	}
+
+	c, err := NewClientWithOptions(nil, EndpointsOption("https://mail1.example.com", "http://mail2.example.com:8080"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Protocol != "https" || c.Host != "mail1.example.com" || c.Port != "443" {
+		t.Errorf("unexpected server: %s://%s:%s", c.Protocol, c.Host, c.Port)
+	}
+}
+
+func TestFailover(t *testing.T) {
+	a, closeA := startFailoverNode("a")
+	defer closeA()
+	b, closeB := startFailoverNode("b")
+	defer closeB()
+
+	c, err := NewClientWithOptions(nil, EndpointsOption(a.url, b.url))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reads are distributed over the endpoints.
+	for i := 0; i < 4; i++ {
+		if _, err := c.Domains.List(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(a.log()) != 2 || len(b.log()) != 2 {
+		t.Fatalf("expected reads to be distributed, got: %v, %v", a.log(), b.log())
+	}
+
+	// Writes stick to the primary endpoint.
+	for _, name := range []string{"one.com", "two.com"} {
+		if err := c.Domains.Create(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if a.fs.domain("two.com") == nil || b.fs.domain("one.com") != nil {
+		t.Fatal("expected writes to be sent to the primary endpoint")
+	}
+
+	// A write failing on the primary endpoint is retried on the next one, which becomes
+	// the primary endpoint.
+	a.setDown(true)
+	if err := c.Domains.Create("three.com"); err != nil {
+		t.Fatal(err)
+	}
+	if b.fs.domain("three.com") == nil {
+		t.Fatal("expected write to fail over")
+	}
+	status := c.Endpoints()
+	if status[0].Healthy || status[0].Primary || !status[1].Primary || status[0].LastError == nil {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+
+	// Reads skip the unhealthy endpoint.
+	n := len(a.log())
+	for i := 0; i < 3; i++ {
+		if _, err := c.Domains.List(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(a.log()) != n {
+		t.Fatalf("expected reads to skip unhealthy endpoint, got: %v", a.log()[n:])
+	}
+
+	// A recovered endpoint becomes healthy, but the primary endpoint is kept.
+	a.setDown(false)
+	status = c.CheckEndpoints()
+	if !status[0].Healthy || status[0].Primary || !status[1].Primary {
+		t.Fatalf("unexpected status after health check: %+v", status)
+	}
+
+	// A health check elects a new primary endpoint if the primary is down.
+	b.setDown(true)
+	status = c.CheckEndpoints()
+	if status[1].Healthy || !status[0].Primary {
+		t.Fatalf("unexpected status after health check: %+v", status)
+	}
+
+	// Requests fail if all endpoints fail.
+	a.setDown(true)
+	if _, err := c.Domains.List(); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestFailover_Auth(t *testing.T) {
+	a, closeA := startFailoverNode("a")
+	defer closeA()
+	b, closeB := startFailoverNode("b")
+	defer closeB()
+	a.auth, b.auth = true, true
+
+	c, err := NewClientWithOptions(nil, EndpointsOption(a.url, b.url))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AuthenticateEndpoints("admin@example.com", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if c.AuthToken != "a-token" || c.RefreshToken != "a-refresh" || c.Login != "admin@example.com" {
+		t.Fatalf("unexpected client tokens: %s, %s", c.AuthToken, c.RefreshToken)
+	}
+	for _, s := range c.Endpoints() {
+		if !s.Authenticated {
+			t.Fatalf("expected %s to be authenticated", s.URL)
+		}
+	}
+
+	// Each endpoint receives its own token.
+	for i := 0; i < 2; i++ {
+		if _, err := c.Domains.List(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A write retried on another endpoint uses the token of that endpoint.
+	a.setDown(true)
+	if err := c.Domains.Create("one.com"); err != nil {
+		t.Fatal(err)
+	}
+	if b.fs.domain("one.com") == nil {
+		t.Fatal("expected write to fail over")
+	}
+	a.setDown(false)
+
+	// An expired token is refreshed with the refresh token of the same endpoint.
+	b.mu.Lock()
+	b.token = "expired"
+	b.mu.Unlock()
+	if err := c.Domains.Create("two.com"); err != nil {
+		t.Fatal(err)
+	}
+	if b.refresh != 1 || a.refresh != 0 {
+		t.Fatalf("expected a refresh on b only, got: a=%d, b=%d", a.refresh, b.refresh)
+	}
+
+	for _, n := range []*failoverNode{a, b} {
+		for _, r := range n.log() {
+			if strings.Contains(r, "/domains") && !strings.HasSuffix(r, "Bearer "+n.name+"-token") && !strings.HasSuffix(r, " ") {
+				t.Errorf("%s: unexpected request %q", n.name, r)
+			}
+		}
+	}
+}
+
+func TestFailover_RefreshPinned(t *testing.T) {
+	a, closeA := startFailoverNode("a")
+	defer closeA()
+	b, closeB := startFailoverNode("b")
+	defer closeB()
+	a.auth, b.auth = true, true
+
+	// The client holds a refresh token of a, but the endpoints hold no tokens, so the
+	// client refreshes its own tokens when a rejects the request.
+	c, err := NewClientWithOptions(nil, EndpointsOption(a.url, b.url), AuthOption("admin@example.com", "expired", "a-refresh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Domains.List(); err != nil {
+		t.Fatal(err)
+	}
+	if a.refresh != 1 || len(b.log()) != 0 {
+		t.Fatalf("expected refresh and resend on a, got: a=%v, b=%v", a.log(), b.log())
+	}
+	if c.AuthToken != "a-token" {
+		t.Fatalf("unexpected client token: %s", c.AuthToken)
+	}
+}
@@ -0,0 +1,414 @@
+package goprsc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeServer is an in-memory implementation of the Postfix REST Server API used by
+// tests that exercise workflows spanning several services.
+type fakeServer struct {
+	mu sync.Mutex
+
+	nextID    int
+	domains   []*Domain
+	accounts  map[string][]*Account
+	aliases   map[string][]*Alias
+	bccs      map[string]*Bcc
+	passwords map[string]string
+
+	// fail, if set, is consulted before handling each request. Returning true makes
+	// the server respond with 500 Internal Server Error.
+	fail func(r *http.Request) bool
+
+	// requests records the method and path of each handled request.
+	requests []string
+
+	// clock, if set, is used as the creation and update time of objects.
+	clock time.Time
+}
+
+func newFakeServer() *fakeServer {
+	return &fakeServer{
+		accounts:  make(map[string][]*Account),
+		aliases:   make(map[string][]*Alias),
+		bccs:      make(map[string]*Bcc),
+		passwords: make(map[string]string),
+	}
+}
+
+// setupFake starts the test server with a fakeServer mounted on the API path.
+func setupFake() *fakeServer {
+	setup()
+
+	fs := newFakeServer()
+	mux.Handle("/api/v1/", fs)
+	return fs
+}
+
+// startFake starts a separate test server backed by a new fakeServer and returns a
+// client connected to it. The returned function shuts the server down.
+func startFake() (*fakeServer, *Client, func()) {
+	fs := newFakeServer()
+	m := http.NewServeMux()
+	m.Handle("/api/v1/", fs)
+	s := httptest.NewServer(m)
+
+	host, port, err := net.SplitHostPort(s.URL[len("http://"):])
+	if err != nil {
+		panic(err)
+	}
+	c, err := NewClientWithOptions(nil, HostOption(host), PortOption(port))
+	if err != nil {
+		panic(err)
+	}
+	return fs, c, s.Close
+}
+
+func (fs *fakeServer) now() DateTime {
+	if !fs.clock.IsZero() {
+		return DateTime{fs.clock}
+	}
+	return DateTime{time.Date(2017, 1, 2, 15, 47, 59, 0, time.UTC)}
+}
+
+func (fs *fakeServer) id() int {
+	fs.nextID++
+	return fs.nextID
+}
+
+func (fs *fakeServer) addDomain(name string, enabled bool) *Domain {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	d := &Domain{ID: fs.id(), Name: name, Enabled: enabled, Created: fs.now(), Updated: fs.now()}
+	fs.domains = append(fs.domains, d)
+	return d
+}
+
+func (fs *fakeServer) addAccount(domain, username string, enabled bool) *Account {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	d := fs.domain(domain)
+	a := &Account{ID: fs.id(), Username: username, Domain: domain, DomainID: d.ID, Enabled: enabled, Created: fs.now(), Updated: fs.now()}
+	fs.accounts[domain] = append(fs.accounts[domain], a)
+	return a
+}
+
+func (fs *fakeServer) addAlias(domain, name, email string, enabled bool) *Alias {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	a := &Alias{ID: fs.id(), Name: name, Email: email, Enabled: enabled, Created: fs.now(), Updated: fs.now()}
+	fs.aliases[domain] = append(fs.aliases[domain], a)
+	return a
+}
+
+func (fs *fakeServer) addBcc(domain, username, bccType, email string, enabled bool) *Bcc {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	a := fs.account(domain, username)
+	b := &Bcc{ID: fs.id(), AccountID: a.ID, Email: email, Enabled: enabled, Created: fs.now(), Updated: fs.now()}
+	fs.bccs[bccKey(a.ID, bccType)] = b
+	return b
+}
+
+func bccKey(accountID int, bccType string) string {
+	return fmt.Sprintf("%d/%s", accountID, bccType)
+}
+
+func (fs *fakeServer) domain(name string) *Domain {
+	for _, d := range fs.domains {
+		if d.Name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+func (fs *fakeServer) account(domain, username string) *Account {
+	for _, a := range fs.accounts[domain] {
+		if a.Username == username {
+			return a
+		}
+	}
+	return nil
+}
+
+func (fs *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.requests = append(fs.requests, r.Method+" "+r.URL.Path)
+
+	if fs.fail != nil && fs.fail(r) {
+		fs.error(w, r, http.StatusInternalServerError, "injected failure")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/"), "/"), "/")
+	if len(parts) == 0 || parts[0] != domainsURL {
+		fs.error(w, r, http.StatusNotFound, "not found")
+		return
+	}
+
+	switch {
+	case len(parts) == 1:
+		fs.handleDomains(w, r)
+	case len(parts) == 2:
+		fs.handleDomain(w, r, parts[1])
+	case fs.domain(parts[1]) == nil:
+		fs.error(w, r, http.StatusNotFound, "domain not found")
+	case parts[2] == "accounts" && len(parts) == 3:
+		fs.handleAccounts(w, r, parts[1])
+	case parts[2] == "accounts" && len(parts) == 4:
+		fs.handleAccount(w, r, parts[1], parts[3])
+	case parts[2] == "accounts" && len(parts) == 6 && parts[4] == "bccs":
+		fs.handleBcc(w, r, parts[1], parts[3], parts[5])
+	case parts[2] == "aliases" && len(parts) == 3:
+		fs.handleAliases(w, r, parts[1])
+	case parts[2] == "aliases" && len(parts) == 4:
+		fs.handleAliasName(w, r, parts[1], parts[3])
+	case parts[2] == "aliases" && len(parts) == 5:
+		fs.handleAlias(w, r, parts[1], parts[3], parts[4])
+	default:
+		fs.error(w, r, http.StatusNotFound, "not found")
+	}
+}
+
+func (fs *fakeServer) error(w http.ResponseWriter, r *http.Request, status int, message string) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"message": message, "path": r.URL.Path, "method": r.Method})
+}
+
+func (fs *fakeServer) write(w http.ResponseWriter, v interface{}) {
+	json.NewEncoder(w).Encode(v)
+}
+
+func (fs *fakeServer) handleDomains(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		domains := []Domain{}
+		for _, d := range fs.domains {
+			domains = append(domains, *d)
+		}
+		fs.write(w, domains)
+	case http.MethodPost:
+		var ur DomainUpdateRequest
+		json.NewDecoder(r.Body).Decode(&ur)
+		if fs.domain(ur.Name) != nil {
+			fs.error(w, r, http.StatusConflict, "domain exists")
+			return
+		}
+		d := &Domain{ID: fs.id(), Name: ur.Name, Enabled: ur.Enabled, Created: fs.now(), Updated: fs.now()}
+		fs.domains = append(fs.domains, d)
+	}
+}
+
+func (fs *fakeServer) handleDomain(w http.ResponseWriter, r *http.Request, name string) {
+	d := fs.domain(name)
+	if d == nil {
+		fs.error(w, r, http.StatusNotFound, "domain not found")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		fs.write(w, d)
+	case http.MethodPut:
+		var ur DomainUpdateRequest
+		json.NewDecoder(r.Body).Decode(&ur)
+		if ur.Name != "" && ur.Name != name {
+			if fs.domain(ur.Name) != nil {
+				fs.error(w, r, http.StatusConflict, "domain exists")
+				return
+			}
+			d.Name = ur.Name
+			for _, a := range fs.accounts[name] {
+				a.Domain = ur.Name
+			}
+			fs.accounts[ur.Name], fs.aliases[ur.Name] = fs.accounts[name], fs.aliases[name]
+			delete(fs.accounts, name)
+			delete(fs.aliases, name)
+		}
+		d.Enabled = ur.Enabled
+		d.Updated = fs.now()
+	case http.MethodDelete:
+		for i, v := range fs.domains {
+			if v == d {
+				fs.domains = append(fs.domains[:i], fs.domains[i+1:]...)
+				break
+			}
+		}
+		delete(fs.accounts, name)
+		delete(fs.aliases, name)
+	}
+}
+
+func (fs *fakeServer) handleAccounts(w http.ResponseWriter, r *http.Request, domain string) {
+	switch r.Method {
+	case http.MethodGet:
+		accounts := []Account{}
+		for _, a := range fs.accounts[domain] {
+			accounts = append(accounts, *a)
+		}
+		fs.write(w, accounts)
+	case http.MethodPost:
+		var ur AccountUpdateRequest
+		json.NewDecoder(r.Body).Decode(&ur)
+		if fs.account(domain, ur.Username) != nil {
+			fs.error(w, r, http.StatusConflict, "account exists")
+			return
+		}
+		a := &Account{ID: fs.id(), Username: ur.Username, Domain: domain, DomainID: fs.domain(domain).ID, Enabled: ur.Enabled, Created: fs.now(), Updated: fs.now()}
+		fs.accounts[domain] = append(fs.accounts[domain], a)
+		fs.passwords[ur.Username+"@"+domain] = ur.Password
+	}
+}
+
+func (fs *fakeServer) handleAccount(w http.ResponseWriter, r *http.Request, domain, username string) {
+	a := fs.account(domain, username)
+	if a == nil {
+		fs.error(w, r, http.StatusNotFound, "account not found")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		fs.write(w, a)
+	case http.MethodPut:
+		var ur AccountUpdateRequest
+		json.NewDecoder(r.Body).Decode(&ur)
+		if ur.Username != "" && ur.Username != username {
+			delete(fs.passwords, username+"@"+domain)
+			a.Username = ur.Username
+		}
+		if ur.Password != "" {
+			fs.passwords[a.Username+"@"+domain] = ur.Password
+		}
+		a.Enabled = ur.Enabled
+		a.Updated = fs.now()
+	case http.MethodDelete:
+		accounts := fs.accounts[domain]
+		for i, v := range accounts {
+			if v == a {
+				fs.accounts[domain] = append(accounts[:i], accounts[i+1:]...)
+				break
+			}
+		}
+		delete(fs.bccs, bccKey(a.ID, IncomingBccType))
+		delete(fs.bccs, bccKey(a.ID, OutgoingBccType))
+		delete(fs.passwords, username+"@"+domain)
+	}
+}
+
+func (fs *fakeServer) handleBcc(w http.ResponseWriter, r *http.Request, domain, username, bccType string) {
+	a := fs.account(domain, username)
+	if a == nil {
+		fs.error(w, r, http.StatusNotFound, "account not found")
+		return
+	}
+	key := bccKey(a.ID, bccType)
+	b := fs.bccs[key]
+	if b == nil && r.Method != http.MethodPost {
+		fs.error(w, r, http.StatusNotFound, "bcc not found")
+		return
+	}
+	var ur BccUpdateRequest
+	switch r.Method {
+	case http.MethodGet:
+		fs.write(w, b)
+	case http.MethodPost:
+		if b != nil {
+			fs.error(w, r, http.StatusConflict, "bcc exists")
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&ur)
+		fs.bccs[key] = &Bcc{ID: fs.id(), AccountID: a.ID, Email: ur.Email, Enabled: ur.Enabled, Created: fs.now(), Updated: fs.now()}
+	case http.MethodPut:
+		json.NewDecoder(r.Body).Decode(&ur)
+		if ur.Email != "" {
+			b.Email = ur.Email
+		}
+		b.Enabled = ur.Enabled
+		b.Updated = fs.now()
+	case http.MethodDelete:
+		delete(fs.bccs, key)
+	}
+}
+
+func (fs *fakeServer) handleAliases(w http.ResponseWriter, r *http.Request, domain string) {
+	switch r.Method {
+	case http.MethodGet:
+		aliases := []Alias{}
+		for _, a := range fs.aliases[domain] {
+			aliases = append(aliases, *a)
+		}
+		fs.write(w, aliases)
+	case http.MethodPost:
+		var ur AliasUpdateRequest
+		json.NewDecoder(r.Body).Decode(&ur)
+		if fs.alias(domain, ur.Name, ur.Email) != nil {
+			fs.error(w, r, http.StatusConflict, "alias exists")
+			return
+		}
+		a := &Alias{ID: fs.id(), Name: ur.Name, Email: ur.Email, Enabled: ur.Enabled, Created: fs.now(), Updated: fs.now()}
+		fs.aliases[domain] = append(fs.aliases[domain], a)
+	}
+}
+
+func (fs *fakeServer) alias(domain, name, email string) *Alias {
+	for _, a := range fs.aliases[domain] {
+		if a.Name == name && a.Email == email {
+			return a
+		}
+	}
+	return nil
+}
+
+func (fs *fakeServer) handleAliasName(w http.ResponseWriter, r *http.Request, domain, name string) {
+	aliases := []Alias{}
+	for _, a := range fs.aliases[domain] {
+		if a.Name == name {
+			aliases = append(aliases, *a)
+		}
+	}
+	if len(aliases) == 0 {
+		fs.error(w, r, http.StatusNotFound, "alias not found")
+		return
+	}
+	fs.write(w, aliases)
+}
+
+func (fs *fakeServer) handleAlias(w http.ResponseWriter, r *http.Request, domain, name, email string) {
+	a := fs.alias(domain, name, email)
+	if a == nil {
+		fs.error(w, r, http.StatusNotFound, "alias not found")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		fs.write(w, a)
+	case http.MethodPut:
+		var ur AliasUpdateRequest
+		json.NewDecoder(r.Body).Decode(&ur)
+		if ur.Name != "" {
+			a.Name = ur.Name
+		}
+		if ur.Email != "" {
+			a.Email = ur.Email
+		}
+		a.Enabled = ur.Enabled
+		a.Updated = fs.now()
+	case http.MethodDelete:
+		aliases := fs.aliases[domain]
+		for i, v := range aliases {
+			if v == a {
+				fs.aliases[domain] = append(aliases[:i], aliases[i+1:]...)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package goprsc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrUnsupported is returned for requests to endpoints which the capability probe found
+// to be unavailable on the server.
+var ErrUnsupported = errors.New("operation not supported by the server")
+
+// Feature is a group of API endpoints which may be unavailable on some servers.
+type Feature string
+
+// Features detected by the capability probe.
+const (
+	FeatureAuth        Feature = "auth"
+	FeatureDomains     Feature = "domains"
+	FeatureAccounts    Feature = "accounts"
+	FeatureAliases     Feature = "aliases"
+	FeatureIncomingBcc Feature = "incoming-bcc"
+	FeatureOutgoingBcc Feature = "outgoing-bcc"
+)
+
+// UnknownAPIVersion is the API version of servers which serve none of the known API
+// versions.
+const UnknownAPIVersion = "unknown"
+
+// apiVersions lists the known API versions, newest first.
+var apiVersions = []string{"v1"}
+
+// probeDomain is a domain which can't exist, used to probe the domain endpoints.
+const probeDomain = "goprsc-probe.invalid"
+
+// probes lists the request used to detect each feature.
+var probes = []struct {
+	feature Feature
+	method  string
+	path    string
+}{
+	{FeatureDomains, http.MethodGet, domainsURL},
+	{FeatureAuth, http.MethodPost, authURL + "/refresh-token"},
+	{FeatureAccounts, http.MethodGet, getAccountsURL(probeDomain)},
+	{FeatureAliases, http.MethodGet, getAliasesURL(probeDomain)},
+	{FeatureIncomingBcc, http.MethodGet, getAccountsURL(probeDomain) + "/probe/bccs/" + IncomingBccType},
+	{FeatureOutgoingBcc, http.MethodGet, getAccountsURL(probeDomain) + "/probe/bccs/" + OutgoingBccType},
+}
+
+// ServerHealth is the result of a health check.
+type ServerHealth struct {
+	// Latency is the duration of the health check request.
+	Latency time.Duration
+
+	// StatusCode is the HTTP status of the health check response.
+	StatusCode int
+
+	// Authenticated reports whether the client credentials were accepted.
+	Authenticated bool
+}
+
+// ServerCapabilities describes the API version and features available on a server.
+type ServerCapabilities struct {
+	// APIVersion is the newest known API version served by the server (e.g. "v1") or
+	// UnknownAPIVersion.
+	APIVersion string
+
+	// Features maps each probed feature to whether it is available.
+	Features map[Feature]bool
+
+	// Probed is the time of the probe.
+	Probed time.Time
+}
+
+// Supports reports whether the feature is available on the server.
+func (c *ServerCapabilities) Supports(f Feature) bool {
+	return c.Features[f]
+}
+
+// Health checks whether the configured server is a working Postfix REST Server. It
+// returns an error if the server can't be reached or doesn't serve the API. The response
+// to unauthenticated clients is considered healthy. The check is sent like any other
+// request, so with EndpointsOption it reports on the endpoint which served it; use
+// CheckEndpoints to check all endpoints.
+func (c *Client) Health() (*ServerHealth, error) {
+	start := time.Now()
+	resp, supported, err := c.probe(http.MethodGet, domainsURL)
+	if err != nil {
+		return nil, err
+	}
+	h := &ServerHealth{
+		Latency:       time.Since(start),
+		StatusCode:    resp.StatusCode,
+		Authenticated: resp.StatusCode >= 200 && resp.StatusCode <= 299,
+	}
+	if !supported {
+		return h, fmt.Errorf("%s://%s:%s is not a Postfix REST Server", c.Protocol, c.Host, c.Port)
+	}
+	if resp.StatusCode >= 500 {
+		return h, fmt.Errorf("server error: %s", resp.Status)
+	}
+	return h, nil
+}
+
+// Ping returns an error if the configured server is not a working Postfix REST Server.
+func (c *Client) Ping() error {
+	_, err := c.Health()
+	return err
+}
+
+// Capabilities returns the capabilities of the server. The server is probed on the first
+// call and the result is cached on the client. Once cached, requests to unavailable
+// endpoints fail with ErrUnsupported without being sent.
+func (c *Client) Capabilities() (*ServerCapabilities, error) {
+	c.capMu.Lock()
+	caps := c.capabilities
+	c.capMu.Unlock()
+	if caps != nil {
+		return caps, nil
+	}
+	return c.ProbeCapabilities()
+}
+
+// ProbeCapabilities probes the server and replaces the cached capabilities. Endpoints
+// are reported as available when the server refuses the credentials of the client, as
+// their existence can't be determined. The authentication API is probed with a token
+// refresh request without a token, which the server rejects.
+func (c *Client) ProbeCapabilities() (*ServerCapabilities, error) {
+	caps := &ServerCapabilities{Features: make(map[Feature]bool), Probed: time.Now()}
+	for _, p := range probes {
+		resp, supported, err := c.probe(p.method, p.path)
+		if err != nil {
+			return nil, err
+		}
+		if p.feature == FeatureDomains && resp.StatusCode >= 500 {
+			return nil, fmt.Errorf("server error: %s", resp.Status)
+		}
+		caps.Features[p.feature] = supported
+	}
+	version, err := c.probeAPIVersion()
+	if err != nil {
+		return nil, err
+	}
+	caps.APIVersion = version
+
+	c.capMu.Lock()
+	c.capabilities = caps
+	c.capMu.Unlock()
+	return caps, nil
+}
+
+// probeAPIVersion returns the newest known API version whose domains endpoint exists on
+// the server or UnknownAPIVersion.
+func (c *Client) probeAPIVersion() (string, error) {
+	for _, v := range apiVersions {
+		resp, supported, err := c.probe(http.MethodGet, "/api/"+v+"/"+domainsURL)
+		if err != nil {
+			return "", err
+		}
+		if supported && resp.StatusCode < 500 {
+			return v, nil
+		}
+	}
+	return UnknownAPIVersion, nil
+}
+
+// probe sends a request bypassing the capability check of Do and reports whether the
+// endpoint exists. Endpoints answering 404 Not Found without an API error, or 405 Method
+// Not Allowed, don't exist.
+func (c *Client) probe(method, path string) (*http.Response, bool, error) {
+	var body interface{}
+	if method == http.MethodPost {
+		body = struct{}{}
+	}
+	req, err := c.NewRequest(method, path, body)
+	if err != nil {
+		return nil, false, err
+	}
+	resp, err := c.send(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	data, _ := ioutil.ReadAll(resp.Body)
+
+	switch resp.StatusCode {
+	case http.StatusMethodNotAllowed:
+		return resp, false, nil
+	case http.StatusNotFound:
+		var e ErrorResponse
+		return resp, json.Unmarshal(data, &e) == nil && len(e.Method) > 0, nil
+	}
+	return resp, true, nil
+}
+
+// checkSupported returns ErrUnsupported if the request targets a feature which the
+// cached capabilities report as unavailable.
+func (c *Client) checkSupported(req *http.Request) error {
+	c.capMu.Lock()
+	caps := c.capabilities
+	c.capMu.Unlock()
+	if caps == nil {
+		return nil
+	}
+	if f := pathFeature(apiPath(req)); len(f) > 0 && !caps.Supports(f) {
+		return ErrUnsupported
+	}
+	return nil
+}
+
+// pathFeature returns the feature of the API path or "" if it is not recognized.
+func pathFeature(path string) Feature {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case parts[0] == authURL:
+		return FeatureAuth
+	case parts[0] != domainsURL:
+		return ""
+	case len(parts) <= 2:
+		return FeatureDomains
+	case parts[2] == "aliases":
+		return FeatureAliases
+	case parts[2] == "accounts" && len(parts) == 6 && parts[4] == "bccs":
+		switch parts[5] {
+		case IncomingBccType:
+			return FeatureIncomingBcc
+		case OutgoingBccType:
+			return FeatureOutgoingBcc
+		}
+		return ""
+	case parts[2] == "accounts":
+		return FeatureAccounts
+	}
+	return ""
+}
@@ -0,0 +1,128 @@
+package goprsc
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestClient_Ping(t *testing.T) {
+	fs := setupFake()
+	defer shutdown()
+	fs.addDomain("example.com", true)
+
+	h, err := client.Health()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.StatusCode != http.StatusOK || !h.Authenticated {
+		t.Errorf("unexpected health: %#v", h)
+	}
+
+	other, err := NewClientWithOptions(nil, HostOption(client.Host), PortOption(client.Port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	shutdown()
+	if err := other.Ping(); err == nil {
+		t.Fatal("expected ping of a stopped server to fail")
+	}
+}
+
+func TestClient_PingNotAServer(t *testing.T) {
+	setup()
+	defer shutdown()
+
+	if err := client.Ping(); err == nil {
+		t.Fatal("expected ping of a server without the API to fail")
+	}
+}
+
+func TestClient_Capabilities(t *testing.T) {
+	setup()
+	defer shutdown()
+
+	apiNotFound := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"message":"domain not found","path":%q,"method":%q}`, r.URL.Path, r.Method)
+	}
+	var bccRequests int
+	mux.HandleFunc("/api/v1/domains", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[]`)
+	})
+	mux.HandleFunc("/api/v1/auth/refresh-token", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	mux.HandleFunc("/api/v1/domains/"+probeDomain+"/accounts", apiNotFound)
+	mux.HandleFunc("/api/v1/domains/"+probeDomain+"/aliases", apiNotFound)
+	mux.HandleFunc("/api/v1/domains/example.com/accounts/john/bccs/incoming", func(w http.ResponseWriter, r *http.Request) {
+		bccRequests++
+		http.NotFound(w, r)
+	})
+
+	caps, err := client.Capabilities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[Feature]bool{
+		FeatureDomains:     true,
+		FeatureAuth:        true,
+		FeatureAccounts:    true,
+		FeatureAliases:     true,
+		FeatureIncomingBcc: false,
+		FeatureOutgoingBcc: false,
+	}
+	for f, supported := range expected {
+		if caps.Supports(f) != supported {
+			t.Errorf("%s: expected supported=%v", f, supported)
+		}
+	}
+	if caps.APIVersion != "v1" {
+		t.Errorf("unexpected API version: %s", caps.APIVersion)
+	}
+	if cached, _ := client.Capabilities(); cached != caps {
+		t.Error("expected capabilities to be cached")
+	}
+
+	if _, err := client.InputBccs.Get("example.com", "john"); err != ErrUnsupported {
+		t.Fatalf("expected ErrUnsupported, got: %v", err)
+	}
+	if bccRequests != 0 {
+		t.Fatal("expected unsupported request not to be sent")
+	}
+	if _, err := client.Domains.List(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClient_CapabilitiesUnknownVersion(t *testing.T) {
+	setup()
+	defer shutdown()
+
+	caps, err := client.ProbeCapabilities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if caps.APIVersion != UnknownAPIVersion || caps.Supports(FeatureDomains) {
+		t.Fatalf("unexpected capabilities: %#v", caps)
+	}
+}
+
+func TestClient_PingEndpoints(t *testing.T) {
+	a, closeA := startFailoverNode("a")
+	defer closeA()
+	b, closeB := startFailoverNode("b")
+	defer closeB()
+
+	c, err := NewClientWithOptions(nil, EndpointsOption(a.url, b.url))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.setDown(true)
+	if err := c.Ping(); err != nil {
+		t.Fatalf("expected health check to fail over, got: %v", err)
+	}
+	if len(b.log()) != 1 {
+		t.Fatalf("expected health check on b, got: %v", b.log())
+	}
+}
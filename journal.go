@@ -0,0 +1,324 @@
+package goprsc
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redacted replaces the values of sensitive fields in journaled request bodies.
+const redacted = "[REDACTED]"
+
+// JournalRecord is a journal entry describing a mutation made by a client.
+type JournalRecord struct {
+	Time  time.Time `json:"time"`
+	Login string    `json:"login"`
+
+	// Operation is "create", "update" or "delete".
+	Operation string `json:"operation"`
+
+	Method string         `json:"method"`
+	Path   string         `json:"path"`
+	Target MutationTarget `json:"target"`
+
+	// Request is the request body with passwords and tokens redacted.
+	Request json.RawMessage `json:"request,omitempty"`
+
+	Success bool   `json:"success"`
+	Status  int    `json:"status,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Journal is an append-only JSON lines file recording the mutations made by clients.
+// When the file exceeds MaxSize it is rotated: path is renamed to path.1, path.1 to path.2
+// and so on, keeping at most MaxBackups rotated files.
+//
+// If Chain is set, the journal is written in a tamper-evident format in which each line
+// carries the hash of the previous one and which can be checked with VerifyJournal.
+type Journal struct {
+	// Path is the path of the journal file.
+	Path string
+
+	// MaxSize is the size in bytes after which the file is rotated. Zero disables rotation.
+	MaxSize int64
+
+	// MaxBackups is the number of rotated files to keep. Zero keeps all rotated files.
+	// Ignored for chained journals, which always keep all rotated files.
+	MaxBackups int
+
+	// ErrorHandler, if set, is called with the errors from writing records of observed
+	// mutations.
+	ErrorHandler func(err error)
+
+	// Chain enables the hash-chained format. Rotated files are part of the chain, so
+	// none of them are deleted.
+	Chain bool
+
+	// SigningKey, if set, is used to sign a checkpoint every CheckpointInterval records
+	// and when the journal is closed. Only used for chained journals.
+	SigningKey ed25519.PrivateKey
+
+	// CheckpointInterval is the number of records between two checkpoints (defaults to 100).
+	CheckpointInterval int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+
+	chain journalChainState
+}
+
+// JournalOption is a client option for recording all mutations made by the client in j.
+func JournalOption(j *Journal) ClientOption {
+	return func(c *Client) error {
+		j.Observe(c)
+		return nil
+	}
+}
+
+// Observe records all mutations made by c in the journal.
+func (j *Journal) Observe(c *Client) {
+	c.AddMutationObserver(func(m *Mutation) {
+		if err := j.Record(m); err != nil && j.ErrorHandler != nil {
+			j.ErrorHandler(err)
+		}
+	})
+}
+
+// Record appends a record describing m to the journal.
+func (j *Journal) Record(m *Mutation) error {
+	target, _ := m.Target()
+	r := &JournalRecord{
+		Time:      m.Time.UTC(),
+		Login:     m.Login,
+		Operation: m.Operation(),
+		Method:    m.Method,
+		Path:      m.Path,
+		Target:    target,
+		Request:   redactBody(m.Body),
+		Success:   m.Succeeded(),
+		Status:    m.StatusCode,
+	}
+	if m.Err != nil {
+		r.Error = m.Err.Error()
+	}
+	return j.Write(r)
+}
+
+// Write appends r to the journal.
+func (j *Journal) Write(r *JournalRecord) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.Chain {
+		return j.writeChained(r)
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return j.writeLine(line)
+}
+
+func (j *Journal) writeLine(line []byte) error {
+	if j.file == nil {
+		if err := j.open(); err != nil {
+			return err
+		}
+	}
+	line = append(line, '\n')
+	if j.MaxSize > 0 && j.size > 0 && j.size+int64(len(line)) > j.MaxSize {
+		if err := j.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := j.file.Write(line)
+	j.size += int64(n)
+	return err
+}
+
+// Close closes the journal file. The file is reopened by the next write. A chained
+// journal with a signing key is sealed with a checkpoint before closing.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var err error
+	if j.Chain && j.SigningKey != nil && j.chain.sinceCheckpoint > 0 {
+		err = j.writeCheckpoint()
+	}
+	if j.file == nil {
+		return err
+	}
+	if cerr := j.file.Close(); err == nil {
+		err = cerr
+	}
+	j.file = nil
+	return err
+}
+
+func (j *Journal) open() error {
+	f, err := os.OpenFile(j.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	j.file, j.size = f, fi.Size()
+	return nil
+}
+
+func (j *Journal) rotate() error {
+	if err := j.file.Close(); err != nil {
+		return err
+	}
+	j.file = nil
+
+	// Deleting the oldest file would break the chain.
+	maxBackups := j.MaxBackups
+	if j.Chain {
+		maxBackups = 0
+	}
+	n := 1
+	for ; maxBackups == 0 || n < maxBackups; n++ {
+		if _, err := os.Stat(journalBackup(j.Path, n)); os.IsNotExist(err) {
+			break
+		}
+	}
+	for i := n; i > 1; i-- {
+		if err := os.Rename(journalBackup(j.Path, i-1), journalBackup(j.Path, i)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(j.Path, journalBackup(j.Path, 1)); err != nil {
+		return err
+	}
+	return j.open()
+}
+
+func journalBackup(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+// journalFiles returns the existing files of the journal at path, oldest first.
+func journalFiles(path string) []string {
+	var backups []string
+	for n := 1; ; n++ {
+		if _, err := os.Stat(journalBackup(path, n)); err != nil {
+			break
+		}
+		backups = append([]string{journalBackup(path, n)}, backups...)
+	}
+	if _, err := os.Stat(path); err == nil {
+		backups = append(backups, path)
+	}
+	return backups
+}
+
+// redactBody returns the JSON body with the values of fields containing "password" or
+// "token" in their name replaced.
+func redactBody(body []byte) json.RawMessage {
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil
+	}
+	for k := range fields {
+		name := strings.ToLower(k)
+		if strings.Contains(name, "password") || strings.Contains(name, "token") {
+			fields[k] = redacted
+		}
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// JournalQuery selects journal records. Zero fields match all records.
+type JournalQuery struct {
+	Since time.Time
+	Until time.Time
+
+	Login     string
+	Operation string
+	Object    ObjectType
+	Domain    string
+
+	// Failed selects only the records of failed mutations.
+	Failed bool
+}
+
+// Match reports whether r is selected by the query.
+func (q *JournalQuery) Match(r *JournalRecord) bool {
+	switch {
+	case !q.Since.IsZero() && r.Time.Before(q.Since):
+	case !q.Until.IsZero() && !r.Time.Before(q.Until):
+	case len(q.Login) > 0 && q.Login != r.Login:
+	case len(q.Operation) > 0 && q.Operation != r.Operation:
+	case len(q.Object) > 0 && q.Object != r.Target.Object:
+	case len(q.Domain) > 0 && !strings.EqualFold(q.Domain, r.Target.Domain):
+	case q.Failed && r.Success:
+	default:
+		return true
+	}
+	return false
+}
+
+// ReadJournal returns the records of the journal at path, including rotated files, which
+// are selected by q. A nil query selects all records. Records are returned oldest first.
+func ReadJournal(path string, q *JournalQuery) ([]JournalRecord, error) {
+	if q == nil {
+		q = &JournalQuery{}
+	}
+	var records []JournalRecord
+	err := scanJournal(path, func(file string, line int, data []byte) error {
+		r, err := decodeJournalLine(data)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", file, line, err)
+		}
+		if r != nil && q.Match(r) {
+			records = append(records, *r)
+		}
+		return nil
+	})
+	return records, err
+}
+
+// scanJournal calls fn for each line of the journal at path, oldest first.
+func scanJournal(path string, fn func(file string, line int, data []byte) error) error {
+	for _, file := range journalFiles(path) {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		s := bufio.NewScanner(f)
+		s.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for n := 1; s.Scan(); n++ {
+			if len(s.Bytes()) == 0 {
+				continue
+			}
+			if err := fn(file, n, s.Bytes()); err != nil {
+				f.Close()
+				return err
+			}
+		}
+		err = s.Err()
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package goprsc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJournal_Observe(t *testing.T) {
+	fs := setupFake()
+	defer shutdown()
+	fs.addDomain("example.com", true)
+
+	dir, err := ioutil.TempDir("", "goprsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	j := &Journal{Path: filepath.Join(dir, "journal.jsonl")}
+	defer j.Close()
+	if err := JournalOption(j)(client); err != nil {
+		t.Fatal(err)
+	}
+	client.Login = "admin"
+
+	if err := client.Accounts.Create("example.com", "john", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Accounts.Create("example.com", "john", "secret"); err == nil {
+		t.Fatal("expected duplicate account to fail")
+	}
+	if err := client.Domains.Delete("example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Domains.List(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(j.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret") {
+		t.Fatalf("password was not redacted: %s", data)
+	}
+
+	records, err := ReadJournal(j.Path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got: %d", len(records))
+	}
+	r := records[0]
+	if r.Login != "admin" || r.Operation != "create" || r.Target.Object != ObjectAccount || r.Target.Account != "john" || !r.Success {
+		t.Errorf("unexpected record: %#v", r)
+	}
+	if !strings.Contains(string(r.Request), redacted) {
+		t.Errorf("expected redacted password in request: %s", r.Request)
+	}
+
+	failed, err := ReadJournal(j.Path, &JournalQuery{Failed: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 1 || failed[0].Status != 409 || len(failed[0].Error) == 0 {
+		t.Fatalf("unexpected failed records: %#v", failed)
+	}
+
+	deleted, err := ReadJournal(j.Path, &JournalQuery{Operation: "delete", Object: ObjectDomain, Domain: "EXAMPLE.COM"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 {
+		t.Fatalf("expected 1 delete record, got: %d", len(deleted))
+	}
+}
+
+func TestJournal_Rotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goprsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	j := &Journal{Path: filepath.Join(dir, "journal.jsonl"), MaxSize: 300, MaxBackups: 2}
+	defer j.Close()
+
+	start := time.Date(2017, 1, 2, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		r := &JournalRecord{Time: start.Add(time.Duration(i) * time.Minute), Login: "admin", Operation: "create", Success: true}
+		if err := j.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files := journalFiles(j.Path)
+	if len(files) != 3 {
+		t.Fatalf("expected 3 journal files, got: %v", files)
+	}
+	for _, f := range files {
+		fi, err := os.Stat(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Size() > j.MaxSize {
+			t.Errorf("%s exceeds the maximum size: %d", f, fi.Size())
+		}
+	}
+
+	records, err := ReadJournal(j.Path, &JournalQuery{Since: start.Add(8 * time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || !records[1].Time.Equal(start.Add(9*time.Minute)) {
+		t.Fatalf("unexpected records: %#v", records)
+	}
+}
@@ -0,0 +1,249 @@
+package goprsc
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const defaultJournalCheckpointInterval = 100
+
+// journalChainLine is a line of a chained journal. Hash is the SHA-256 hash of the exact
+// bytes of Entry.
+type journalChainLine struct {
+	Hash  string          `json:"hash"`
+	Entry json.RawMessage `json:"entry"`
+}
+
+// journalChainEntry is either a record or a checkpoint linked to the previous entry.
+type journalChainEntry struct {
+	Seq        uint64             `json:"seq"`
+	Prev       string             `json:"prev"`
+	Record     *JournalRecord     `json:"record,omitempty"`
+	Checkpoint *JournalCheckpoint `json:"checkpoint,omitempty"`
+}
+
+// JournalCheckpoint is a signed statement of the state of the journal chain. The
+// signature covers the sequence number of the checkpoint and the hash of the entry
+// preceding it.
+type JournalCheckpoint struct {
+	Time      time.Time `json:"time"`
+	Signature []byte    `json:"signature"`
+}
+
+type journalChainState struct {
+	loaded          bool
+	seq             uint64
+	prev            string
+	sinceCheckpoint int
+}
+
+func checkpointMessage(seq uint64, prev string) []byte {
+	return []byte(fmt.Sprintf("goprsc journal checkpoint %d %s", seq, prev))
+}
+
+// decodeJournalLine decodes a plain or chained journal line. It returns nil for checkpoints.
+func decodeJournalLine(data []byte) (*JournalRecord, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte(`{"hash"`)) {
+		var r JournalRecord
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, err
+		}
+		return &r, nil
+	}
+
+	var line journalChainLine
+	if err := json.Unmarshal(data, &line); err != nil {
+		return nil, err
+	}
+	var e journalChainEntry
+	if err := json.Unmarshal(line.Entry, &e); err != nil {
+		return nil, err
+	}
+	return e.Record, nil
+}
+
+// loadChain restores the chain state from the existing journal files.
+func (j *Journal) loadChain() error {
+	if j.chain.loaded {
+		return nil
+	}
+
+	var st journalChainState
+	err := scanJournal(j.Path, func(file string, n int, data []byte) error {
+		var line journalChainLine
+		var e journalChainEntry
+		if err := json.Unmarshal(data, &line); err != nil {
+			return fmt.Errorf("%s:%d: %v", file, n, err)
+		}
+		if err := json.Unmarshal(line.Entry, &e); err != nil {
+			return fmt.Errorf("%s:%d: %v", file, n, err)
+		}
+		st.seq, st.prev = e.Seq, line.Hash
+		if e.Checkpoint != nil {
+			st.sinceCheckpoint = 0
+		} else {
+			st.sinceCheckpoint++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	st.loaded = true
+	j.chain = st
+	return nil
+}
+
+func (j *Journal) writeChained(r *JournalRecord) error {
+	if err := j.loadChain(); err != nil {
+		return err
+	}
+	if err := j.appendEntry(&journalChainEntry{Record: r}); err != nil {
+		return err
+	}
+	j.chain.sinceCheckpoint++
+
+	interval := j.CheckpointInterval
+	if interval <= 0 {
+		interval = defaultJournalCheckpointInterval
+	}
+	if j.SigningKey != nil && j.chain.sinceCheckpoint >= interval {
+		return j.writeCheckpoint()
+	}
+	return nil
+}
+
+// Checkpoint writes a signed checkpoint to a chained journal.
+func (j *Journal) Checkpoint() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if !j.Chain || j.SigningKey == nil {
+		return errors.New("checkpoints require a chained journal with a signing key")
+	}
+	if err := j.loadChain(); err != nil {
+		return err
+	}
+	return j.writeCheckpoint()
+}
+
+func (j *Journal) writeCheckpoint() error {
+	seq := j.chain.seq + 1
+	cp := &JournalCheckpoint{
+		Time:      time.Now().UTC(),
+		Signature: ed25519.Sign(j.SigningKey, checkpointMessage(seq, j.chain.prev)),
+	}
+	if err := j.appendEntry(&journalChainEntry{Checkpoint: cp}); err != nil {
+		return err
+	}
+	j.chain.sinceCheckpoint = 0
+	return nil
+}
+
+func (j *Journal) appendEntry(e *journalChainEntry) error {
+	e.Seq, e.Prev = j.chain.seq+1, j.chain.prev
+	entry, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(entry)
+	hash := hex.EncodeToString(sum[:])
+	line, err := json.Marshal(&journalChainLine{Hash: hash, Entry: entry})
+	if err != nil {
+		return err
+	}
+	if err := j.writeLine(line); err != nil {
+		return err
+	}
+	j.chain.seq, j.chain.prev = e.Seq, hash
+	return nil
+}
+
+// JournalVerification is the result of verifying a chained journal.
+type JournalVerification struct {
+	Records     int
+	Checkpoints int
+
+	// LastSeq is the sequence number of the last entry.
+	LastSeq uint64
+
+	// LastCheckpoint is the sequence number of the last checkpoint or 0 if there is none.
+	LastCheckpoint uint64
+
+	// Sealed reports whether the journal ends with a checkpoint. Records after the last
+	// checkpoint could have been removed without being detected.
+	Sealed bool
+}
+
+// JournalVerifyError describes a violation of the journal integrity.
+type JournalVerifyError struct {
+	File   string
+	Line   int
+	Seq    uint64
+	Reason string
+}
+
+func (e *JournalVerifyError) Error() string {
+	return fmt.Sprintf("%s:%d: entry %d: %s", e.File, e.Line, e.Seq, e.Reason)
+}
+
+// VerifyJournal checks the hash chain of the journal at path, including rotated files, and
+// the signatures of its checkpoints with key. If key is nil, signatures are not checked.
+// Modified, inserted or removed entries are reported with a *JournalVerifyError.
+func VerifyJournal(path string, key ed25519.PublicKey) (*JournalVerification, error) {
+	v := &JournalVerification{}
+	var prev string
+	err := scanJournal(path, func(file string, n int, data []byte) error {
+		fail := func(seq uint64, format string, a ...interface{}) error {
+			return &JournalVerifyError{File: file, Line: n, Seq: seq, Reason: fmt.Sprintf(format, a...)}
+		}
+
+		var line journalChainLine
+		if err := json.Unmarshal(data, &line); err != nil || len(line.Entry) == 0 {
+			return fail(v.LastSeq+1, "not a chained entry")
+		}
+		var e journalChainEntry
+		if err := json.Unmarshal(line.Entry, &e); err != nil {
+			return fail(v.LastSeq+1, "invalid entry: %v", err)
+		}
+
+		sum := sha256.Sum256(line.Entry)
+		if hex.EncodeToString(sum[:]) != line.Hash {
+			return fail(e.Seq, "hash mismatch, entry was modified")
+		}
+		if v.LastSeq == 0 && e.Seq != 1 {
+			return fail(e.Seq, "journal does not start with entry 1, it was truncated")
+		}
+		if e.Seq != v.LastSeq+1 {
+			return fail(e.Seq, "expected entry %d, entries were inserted or removed", v.LastSeq+1)
+		}
+		if e.Prev != prev {
+			return fail(e.Seq, "previous hash mismatch, entries were inserted, removed or modified")
+		}
+
+		switch {
+		case e.Checkpoint != nil:
+			if key != nil && !ed25519.Verify(key, checkpointMessage(e.Seq, e.Prev), e.Checkpoint.Signature) {
+				return fail(e.Seq, "invalid checkpoint signature")
+			}
+			v.Checkpoints++
+			v.LastCheckpoint = e.Seq
+		case e.Record != nil:
+			v.Records++
+		default:
+			return fail(e.Seq, "entry is neither a record nor a checkpoint")
+		}
+		v.LastSeq, prev = e.Seq, line.Hash
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	v.Sealed = v.LastSeq > 0 && v.LastCheckpoint == v.LastSeq
+	return v, nil
+}
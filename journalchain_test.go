@@ -0,0 +1,157 @@
+package goprsc
+
+import (
+	"crypto/ed25519"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeChainedJournal(t *testing.T, dir string, key ed25519.PrivateKey, records int) string {
+	j := &Journal{Path: filepath.Join(dir, "journal.jsonl"), Chain: true, SigningKey: key, CheckpointInterval: 3}
+	start := time.Date(2017, 1, 2, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < records; i++ {
+		r := &JournalRecord{Time: start.Add(time.Duration(i) * time.Minute), Login: "admin", Operation: "create", Success: true}
+		if err := j.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return j.Path
+}
+
+func TestVerifyJournal(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "goprsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := writeChainedJournal(t, dir, priv, 4)
+
+	v, err := VerifyJournal(path, pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 4 records, a checkpoint after the third record and one on close.
+	if v.Records != 4 || v.Checkpoints != 2 || v.LastSeq != 6 || !v.Sealed {
+		t.Fatalf("unexpected verification: %#v", v)
+	}
+
+	records, err := ReadJournal(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("expected 4 records, got: %d", len(records))
+	}
+
+	// Reopening the journal continues the chain.
+	j := &Journal{Path: path, Chain: true, SigningKey: priv}
+	if err := j.Write(&JournalRecord{Login: "admin", Operation: "delete"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if v, err = VerifyJournal(path, pub); err != nil || v.Records != 5 || !v.Sealed {
+		t.Fatalf("unexpected verification after reopening: %#v, %v", v, err)
+	}
+
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+	if _, err := VerifyJournal(path, otherPub); err == nil {
+		t.Fatal("expected verification with another key to fail")
+	}
+}
+
+func TestVerifyJournal_Tampering(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "goprsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := writeChainedJournal(t, dir, priv, 4)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(strings.TrimSpace(string(data)), "\n")
+
+	testCases := []struct {
+		desc  string
+		lines []string
+	}{
+		{"Modified", append(append([]string{}, lines[:1]...), append([]string{strings.Replace(lines[1], "admin", "guest", 1)}, lines[2:]...)...)},
+		{"Removed", append(append([]string{}, lines[:1]...), lines[2:]...)},
+		{"Inserted", append(append([]string{}, lines[:2]...), lines[1:]...)},
+		{"TruncatedStart", lines[1:]},
+	}
+	for _, tc := range testCases {
+		if err := ioutil.WriteFile(path, []byte(strings.Join(tc.lines, "")), 0600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := VerifyJournal(path, priv.Public().(ed25519.PublicKey))
+		if _, ok := err.(*JournalVerifyError); !ok {
+			t.Errorf("%s: expected *JournalVerifyError, got: %v", tc.desc, err)
+		}
+	}
+
+	if err := ioutil.WriteFile(path, []byte(strings.Join(lines[:len(lines)-1], "")), 0600); err != nil {
+		t.Fatal(err)
+	}
+	v, err := VerifyJournal(path, priv.Public().(ed25519.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Sealed {
+		t.Error("expected truncated journal not to be sealed")
+	}
+}
+
+func TestVerifyJournal_Rotated(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "goprsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// MaxBackups is ignored, as deleting rotated files would truncate the chain.
+	j := &Journal{Path: filepath.Join(dir, "journal.jsonl"), Chain: true, SigningKey: priv, MaxSize: 200, MaxBackups: 1}
+	for i := 0; i < 10; i++ {
+		if err := j.Write(&JournalRecord{Login: "admin", Operation: "create", Success: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if files := journalFiles(j.Path); len(files) < 3 {
+		t.Fatalf("expected several rotated files, got: %v", files)
+	}
+
+	v, err := VerifyJournal(j.Path, pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Records != 10 || !v.Sealed {
+		t.Fatalf("unexpected verification: %#v", v)
+	}
+}
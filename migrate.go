@@ -0,0 +1,300 @@
+package goprsc
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// MigrationOptions configures copying the state of one server to another.
+type MigrationOptions struct {
+	// Passwords maps account addresses (e.g. john@example.com) to the passwords to be
+	// assigned on the target server.
+	Passwords map[string]string
+
+	// GeneratePassword returns a password for accounts missing from Passwords. Defaults to
+	// the password policy of the target client or, without one, random 16 byte passwords.
+	GeneratePassword func(address string) (string, error)
+
+	// Credentials, if set, receives a CSV report of the address and password of each
+	// created account.
+	Credentials io.Writer
+
+	// Checkpoint is the path of a file recording the migrated objects. A migration
+	// interrupted by an error can be resumed by running it again with the same checkpoint.
+	// Objects created by the interrupted run are finished by the resumed one.
+	Checkpoint string
+}
+
+// MigrationResult describes the outcome of a migration.
+type MigrationResult struct {
+	// Created lists the objects created on the target server.
+	Created []string
+
+	// Skipped lists the objects which already existed on the target server or were
+	// migrated by a previous run.
+	Skipped []string
+
+	// PasswordResets lists the addresses of accounts created by an interrupted run before
+	// their password was reported. Their passwords are unknown and must be reset.
+	PasswordResets []string
+
+	// Diff is the difference between the source and the target server after migration.
+	Diff *SnapshotDiff
+}
+
+// Migrate copies all domains, accounts, aliases and BCCs from source to target. Account
+// passwords can't be read from the source server, so new passwords are assigned from
+// opts.Passwords or opts.GeneratePassword. Migrate returns an error if the target server
+// doesn't contain all objects of the source server afterwards.
+func Migrate(source, target *Client, opts *MigrationOptions) (*MigrationResult, error) {
+	if opts == nil {
+		opts = &MigrationOptions{}
+	}
+	generate := opts.GeneratePassword
+	if generate == nil {
+		generate = randomPassword
+		if policy := target.passwordPolicy; policy != nil {
+			generate = func(address string) (string, error) {
+				username, domain := splitAddress(address)
+				return policy.Generate(domain, username)
+			}
+		}
+	}
+
+	cp, err := loadMigrationCheckpoint(opts.Checkpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := source.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	dst, err := target.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	existing := diffRecords(dst)
+
+	var credentials *csv.Writer
+	if opts.Credentials != nil {
+		credentials = csv.NewWriter(opts.Credentials)
+	}
+
+	result := &MigrationResult{}
+	// migrate creates object with create and completes it with finish, which is told
+	// whether the object was created by a previous run that was interrupted before
+	// finishing it. The object is recorded in the checkpoint before it is created, so
+	// that a resumed run finishes it instead of skipping it as existing.
+	migrate := func(object string, create func() error, finish func(resumed bool) error) error {
+		_, exists := existing[object]
+		resumed := exists && cp.Started[object]
+		if cp.Done[object] || exists && !resumed {
+			result.Skipped = append(result.Skipped, object)
+			return nil
+		}
+		if !resumed {
+			if err := cp.start(object); err != nil {
+				return err
+			}
+			if err := create(); err != nil {
+				return fmt.Errorf("migrate %s: %v", object, err)
+			}
+		}
+		if err := finish(resumed); err != nil {
+			return fmt.Errorf("migrate %s: %v", object, err)
+		}
+		result.Created = append(result.Created, object)
+		return cp.done(object)
+	}
+
+	for _, d := range src.Domains {
+		d := d
+		err := migrate("domain "+normalizeAddress(d.Name), func() error {
+			return target.Domains.Create(d.Name)
+		}, func(bool) error {
+			if !d.Enabled {
+				return target.Domains.Update(d.Name, &DomainUpdateRequest{Enabled: false})
+			}
+			return nil
+		})
+		if err != nil {
+			return result, err
+		}
+
+		for _, a := range d.Accounts {
+			a := a
+			address := normalizeAddress(a.Username + "@" + d.Name)
+			var password string
+			err := migrate("account "+address, func() error {
+				var ok bool
+				if password, ok = opts.Passwords[address]; !ok {
+					var err error
+					if password, err = generate(address); err != nil {
+						return err
+					}
+				}
+				return target.Accounts.Create(d.Name, a.Username, password)
+			}, func(resumed bool) error {
+				if resumed {
+					result.PasswordResets = append(result.PasswordResets, address)
+				} else if credentials != nil {
+					credentials.Write([]string{address, password})
+					credentials.Flush()
+					if err := credentials.Error(); err != nil {
+						return err
+					}
+				}
+				if !a.Enabled {
+					return target.Accounts.Update(d.Name, a.Username, &AccountUpdateRequest{Enabled: false})
+				}
+				return nil
+			})
+			if err != nil {
+				return result, err
+			}
+		}
+
+		for _, a := range d.Aliases {
+			a := a
+			object := fmt.Sprintf("alias %s -> %s", normalizeAddress(a.Name+"@"+d.Name), normalizeAddress(a.Email))
+			err := migrate(object, func() error {
+				return target.Aliases.Create(d.Name, a.Name, a.Email)
+			}, func(bool) error {
+				if !a.Enabled {
+					return target.Aliases.Update(d.Name, a.Name, a.Email, &AliasUpdateRequest{Name: a.Name, Email: a.Email, Enabled: false})
+				}
+				return nil
+			})
+			if err != nil {
+				return result, err
+			}
+		}
+
+		for _, b := range d.Bccs() {
+			b := b
+			object := fmt.Sprintf("%s bcc %s", b.Type, normalizeAddress(b.Account+"@"+d.Name))
+			bccs := target.bccService(b.Type)
+			err := migrate(object, func() error {
+				return bccs.Create(d.Name, b.Account, b.Bcc.Email)
+			}, func(bool) error {
+				if !b.Bcc.Enabled {
+					return bccs.Update(d.Name, b.Account, &BccUpdateRequest{Email: b.Bcc.Email, Enabled: false})
+				}
+				return nil
+			})
+			if err != nil {
+				return result, err
+			}
+		}
+	}
+
+	dst, err = target.Snapshot()
+	if err != nil {
+		return result, err
+	}
+	result.Diff = DiffSnapshots(src, dst)
+	for _, e := range result.Diff.Entries {
+		if e.Kind != DiffOnlyRight {
+			return result, fmt.Errorf("migration verification failed: %s %s", e.Kind, e.Object)
+		}
+	}
+	return result, nil
+}
+
+// randomPassword returns a random password with 128 bits of entropy.
+func randomPassword(string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// migrationCheckpoint records the objects migrated to the target server.
+type migrationCheckpoint struct {
+	path string
+	Done map[string]bool `json:"done"`
+
+	// Started records the objects whose creation was started but not finished.
+	Started map[string]bool `json:"started,omitempty"`
+}
+
+func loadMigrationCheckpoint(path string) (*migrationCheckpoint, error) {
+	cp := &migrationCheckpoint{path: path, Done: make(map[string]bool), Started: make(map[string]bool)}
+	if len(path) == 0 {
+		return cp, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cp, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint %s: %v", path, err)
+	}
+	if cp.Done == nil {
+		cp.Done = make(map[string]bool)
+	}
+	if cp.Started == nil {
+		cp.Started = make(map[string]bool)
+	}
+	return cp, nil
+}
+
+// start marks the creation of object as started and saves the checkpoint.
+func (cp *migrationCheckpoint) start(object string) error {
+	cp.Started[object] = true
+	return cp.save()
+}
+
+// done marks object as migrated and saves the checkpoint.
+func (cp *migrationCheckpoint) done(object string) error {
+	delete(cp.Started, object)
+	cp.Done[object] = true
+	return cp.save()
+}
+
+func (cp *migrationCheckpoint) save() error {
+	if len(cp.path) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(cp.path, data, 0600)
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory and renames it
+// to filename, so that readers never see a partially written file.
+func writeFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
@@ -0,0 +1,149 @@
+package goprsc
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func setupMigration() (*fakeServer, *fakeServer, *Client, func()) {
+	src := setupFake()
+	src.addDomain("example.com", true)
+	src.addDomain("example.org", false)
+	src.addAccount("example.com", "john", true)
+	src.addAccount("example.com", "jane", false)
+	src.addAccount("example.org", "info", true)
+	src.addAlias("example.com", "team", "john@example.com", true)
+	src.addAlias("example.com", "old", "jane@example.com", false)
+	src.addBcc("example.com", "john", IncomingBccType, "archive@example.org", true)
+	src.addBcc("example.com", "jane", OutgoingBccType, "audit@example.org", false)
+
+	dst, target, closeTarget := startFake()
+	return src, dst, target, closeTarget
+}
+
+func TestMigrate(t *testing.T) {
+	_, dst, target, closeTarget := setupMigration()
+	defer shutdown()
+	defer closeTarget()
+
+	var credentials bytes.Buffer
+	opts := &MigrationOptions{
+		Passwords:   map[string]string{"john@example.com": "johns-password"},
+		Credentials: &credentials,
+	}
+	result, err := Migrate(client, target, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Created) != 9 || len(result.Skipped) != 0 {
+		t.Fatalf("unexpected result: %#v", result)
+	}
+	if !result.Diff.Empty() {
+		t.Fatalf("unexpected diff: %#v", result.Diff)
+	}
+	if dst.passwords["john@example.com"] != "johns-password" {
+		t.Fatal("password from mapping was not used")
+	}
+	if len(dst.passwords["jane@example.com"]) == 0 {
+		t.Fatal("password was not generated")
+	}
+	lines := strings.Split(strings.TrimSpace(credentials.String()), "\n")
+	if len(lines) != 3 || lines[0] != "john@example.com,johns-password" {
+		t.Fatalf("unexpected credentials report: %q", credentials.String())
+	}
+}
+
+func TestMigrate_Resume(t *testing.T) {
+	_, dst, target, closeTarget := setupMigration()
+	defer shutdown()
+	defer closeTarget()
+
+	dir, err := ioutil.TempDir("", "goprsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	opts := &MigrationOptions{Checkpoint: filepath.Join(dir, "checkpoint.json")}
+
+	dst.fail = func(r *http.Request) bool {
+		return r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/aliases")
+	}
+	result, err := Migrate(client, target, opts)
+	if err == nil {
+		t.Fatal("expected migration to fail")
+	}
+	created := len(result.Created)
+
+	dst.fail = nil
+	result, err = Migrate(client, target, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Skipped) != created || len(result.Created)+created != 9 {
+		t.Fatalf("expected %d skipped objects, got: %#v", created, result)
+	}
+}
+
+func TestMigrate_ResumeAccount(t *testing.T) {
+	_, dst, target, closeTarget := setupMigration()
+	defer shutdown()
+	defer closeTarget()
+
+	dir, err := ioutil.TempDir("", "goprsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	opts := &MigrationOptions{Checkpoint: filepath.Join(dir, "checkpoint.json")}
+
+	// The account is created, but disabling it fails.
+	dst.fail = func(r *http.Request) bool {
+		return r.Method == http.MethodPut && strings.HasSuffix(r.URL.Path, "/accounts/jane")
+	}
+	if _, err := Migrate(client, target, opts); err == nil {
+		t.Fatal("expected migration to fail")
+	}
+	if a := dst.account("example.com", "jane"); a == nil || !a.Enabled {
+		t.Fatalf("expected enabled account on the target, got: %#v", a)
+	}
+
+	dst.fail = nil
+	result, err := Migrate(client, target, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := dst.account("example.com", "jane"); a.Enabled {
+		t.Fatal("expected resumed account to be disabled")
+	}
+	if len(result.PasswordResets) != 1 || result.PasswordResets[0] != "jane@example.com" {
+		t.Fatalf("expected password reset of jane@example.com, got: %v", result.PasswordResets)
+	}
+}
+
+func TestMigrate_PasswordPolicy(t *testing.T) {
+	_, dst, target, closeTarget := setupMigration()
+	defer shutdown()
+	defer closeTarget()
+
+	policy := &PasswordPolicy{MinLength: 12, RequireSymbol: true}
+	if err := PasswordPolicyOption(policy)(target); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Migrate(client, target, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, address := range []string{"john@example.com", "jane@example.com", "info@example.org"} {
+		username, domain := splitAddress(address)
+		if err := policy.Check(domain, username, dst.passwords[address]); err != nil {
+			t.Errorf("%s: %v", address, err)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package goprsc
+
+import (
+	"errors"
+	"fmt"
+)
+
+// AccountMoveOptions configures moving an account to another domain.
+type AccountMoveOptions struct {
+	// Username is the username in the target domain. Defaults to the current username.
+	Username string
+
+	// Password is the password of the account in the target domain. Passwords can't be
+	// read back from the server, so it must be provided.
+	Password string
+
+	// Forward leaves an alias at the old address forwarding mail to the new one.
+	Forward bool
+}
+
+// AccountMoveStep is a single step performed while moving an account.
+type AccountMoveStep struct {
+	Description string
+	Err         error
+}
+
+// AccountMoveReport describes the steps performed while moving an account.
+type AccountMoveReport struct {
+	From  string
+	To    string
+	Steps []AccountMoveStep
+}
+
+func (r *AccountMoveReport) step(err error, format string, a ...interface{}) error {
+	r.Steps = append(r.Steps, AccountMoveStep{Description: fmt.Sprintf(format, a...), Err: err})
+	return err
+}
+
+// Move recreates the account username from domain in targetDomain, moves its incoming and
+// outgoing BCCs, rewrites aliases pointing to the old address and removes the old account.
+// Changes are rolled back if a step fails before the old account is removed. The report
+// lists the changes made and the step which failed, including failed reads.
+func (s *AccountService) Move(domain, username, targetDomain string, opts *AccountMoveOptions) (*AccountMoveReport, error) {
+	if opts == nil || len(opts.Password) == 0 {
+		return nil, errors.New("a password for the moved account is required")
+	}
+	targetUsername := opts.Username
+	if len(targetUsername) == 0 {
+		targetUsername = username
+	}
+
+	report := &AccountMoveReport{
+		From: username + "@" + domain,
+		To:   targetUsername + "@" + targetDomain,
+	}
+	if normalizeAddress(report.From) == normalizeAddress(report.To) {
+		return nil, fmt.Errorf("account %s is already in domain %s", report.From, targetDomain)
+	}
+
+	var undo undoStack
+	fail := func(err error) (*AccountMoveReport, error) {
+		if rerr := undo.rollback(); rerr != nil {
+			return report, fmt.Errorf("move %s to %s: %v (rollback failed: %v)", report.From, report.To, err, rerr)
+		}
+		return report, fmt.Errorf("move %s to %s: %v", report.From, report.To, err)
+	}
+
+	account, err := s.Get(domain, username)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Create(targetDomain, targetUsername, opts.Password)
+	if report.step(err, "create account %s", report.To) != nil {
+		return fail(err)
+	}
+	undo.push(func() error { return s.Delete(targetDomain, targetUsername) })
+
+	if !account.Enabled {
+		err = s.Update(targetDomain, targetUsername, &AccountUpdateRequest{Enabled: false})
+		if report.step(err, "disable account %s", report.To) != nil {
+			return fail(err)
+		}
+	}
+
+	for _, t := range bccTypes {
+		bccs := s.client.bccService(t)
+		bcc, err := bccs.Get(domain, username)
+		if isNotFound(err) {
+			continue
+		} else if err != nil {
+			report.step(err, "read %s bcc of %s", t, report.From)
+			return fail(err)
+		}
+
+		err = bccs.Create(targetDomain, targetUsername, bcc.Email)
+		if err == nil && !bcc.Enabled {
+			err = bccs.Update(targetDomain, targetUsername, &BccUpdateRequest{Email: bcc.Email, Enabled: false})
+		}
+		if report.step(err, "copy %s bcc %s", t, bcc.Email) != nil {
+			return fail(err)
+		}
+	}
+
+	domains, err := s.client.Domains.List()
+	if err != nil {
+		report.step(err, "list domains")
+		return fail(err)
+	}
+	for _, d := range domains {
+		aliases, err := s.client.Aliases.List(d.Name)
+		if err != nil {
+			report.step(err, "list aliases of %s", d.Name)
+			return fail(err)
+		}
+		for _, a := range aliases {
+			if normalizeAddress(a.Email) != normalizeAddress(report.From) {
+				continue
+			}
+			a, dn := a, d.Name
+			err := s.client.Aliases.Update(dn, a.Name, a.Email, &AliasUpdateRequest{Name: a.Name, Email: report.To, Enabled: a.Enabled})
+			if report.step(err, "rewrite alias %s@%s", a.Name, dn) != nil {
+				return fail(err)
+			}
+			undo.push(func() error {
+				return s.client.Aliases.Update(dn, a.Name, report.To, &AliasUpdateRequest{Name: a.Name, Email: a.Email, Enabled: a.Enabled})
+			})
+		}
+	}
+
+	// If the old account can't be deleted, the changes are rolled back like above. Once
+	// it is deleted it can't be restored, so later failures are reported without rolling
+	// back.
+	err = s.Delete(domain, username)
+	if report.step(err, "delete account %s", report.From) != nil {
+		return fail(err)
+	}
+
+	if opts.Forward {
+		err = s.client.Aliases.Create(domain, username, report.To)
+		if report.step(err, "create forwarding alias %s", report.From) != nil {
+			return report, fmt.Errorf("move %s to %s: %v", report.From, report.To, err)
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,117 @@
+package goprsc
+
+import (
+	"net/http"
+	"testing"
+)
+
+func setupMove() *fakeServer {
+	fs := setupFake()
+	fs.addDomain("sales.example.com", true)
+	fs.addDomain("example.com", true)
+	fs.addAccount("sales.example.com", "john", true)
+	fs.addAlias("sales.example.com", "sales", "john@sales.example.com", true)
+	fs.addBcc("sales.example.com", "john", IncomingBccType, "archive@example.com", true)
+	fs.addBcc("sales.example.com", "john", OutgoingBccType, "audit@example.com", false)
+	return fs
+}
+
+func TestAccount_Move(t *testing.T) {
+	fs := setupMove()
+	defer shutdown()
+
+	report, err := client.Accounts.Move("sales.example.com", "john", "example.com", &AccountMoveOptions{Password: "secret", Forward: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Steps) != 6 {
+		t.Fatalf("expected 6 steps, got: %v", report.Steps)
+	}
+	john := fs.account("example.com", "john")
+	if john == nil || fs.account("sales.example.com", "john") != nil {
+		t.Fatal("account was not moved")
+	}
+	if fs.passwords["john@example.com"] != "secret" {
+		t.Fatal("password was not set")
+	}
+	if b := fs.bccs[bccKey(john.ID, OutgoingBccType)]; b == nil || b.Email != "audit@example.com" || b.Enabled {
+		t.Fatalf("unexpected outgoing bcc: %#v", b)
+	}
+	if fs.alias("sales.example.com", "sales", "john@example.com") == nil {
+		t.Fatal("alias was not rewritten")
+	}
+	if fs.alias("sales.example.com", "john", "john@example.com") == nil {
+		t.Fatal("forwarding alias was not created")
+	}
+}
+
+func TestAccount_MoveMixedCase(t *testing.T) {
+	fs := setupMove()
+	defer shutdown()
+	fs.addAlias("example.com", "team", "John@Sales.Example.com", true)
+
+	if _, err := client.Accounts.Move("sales.example.com", "john", "example.com", &AccountMoveOptions{Password: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if fs.alias("example.com", "team", "john@example.com") == nil {
+		t.Fatalf("expected mixed-case alias to be rewritten, got: %#v", fs.aliases["example.com"])
+	}
+}
+
+func TestAccount_MoveRequiresPassword(t *testing.T) {
+	setupMove()
+	defer shutdown()
+
+	if _, err := client.Accounts.Move("sales.example.com", "john", "example.com", nil); err == nil {
+		t.Fatal("expected error without password")
+	}
+}
+
+func TestAccount_MoveRollback(t *testing.T) {
+	fs := setupMove()
+	defer shutdown()
+
+	fs.fail = func(r *http.Request) bool {
+		return r.Method == http.MethodDelete
+	}
+
+	report, err := client.Accounts.Move("sales.example.com", "john", "example.com", &AccountMoveOptions{Password: "secret"})
+	if err == nil {
+		t.Fatal("expected move to fail")
+	}
+	if last := report.Steps[len(report.Steps)-1]; last.Err == nil {
+		t.Fatalf("expected last step to fail: %#v", last)
+	}
+	if fs.alias("sales.example.com", "sales", "john@sales.example.com") == nil {
+		t.Fatal("alias rewrite was not rolled back")
+	}
+}
+
+func TestAccount_MoveFailsPartway(t *testing.T) {
+	fs := setupMove()
+	defer shutdown()
+
+	fs.fail = func(r *http.Request) bool {
+		return r.Method == http.MethodGet && r.URL.Path == "/api/v1/domains/example.com/aliases"
+	}
+
+	report, err := client.Accounts.Move("sales.example.com", "john", "example.com", &AccountMoveOptions{Password: "secret"})
+	if err == nil {
+		t.Fatal("expected move to fail")
+	}
+	// create account, copy two bccs, rewrite the alias in sales.example.com and the
+	// failed listing of the aliases in example.com.
+	if len(report.Steps) != 5 {
+		t.Fatalf("expected 5 steps, got: %v", report.Steps)
+	}
+	if last := report.Steps[4]; last.Err == nil || last.Description != "list aliases of example.com" {
+		t.Fatalf("expected failed alias listing step, got: %#v", last)
+	}
+	if fs.account("example.com", "john") != nil || fs.account("sales.example.com", "john") == nil {
+		t.Fatal("move was not rolled back")
+	}
+	if fs.alias("sales.example.com", "sales", "john@sales.example.com") == nil {
+		t.Fatal("alias rewrite was not rolled back")
+	}
+}
@@ -0,0 +1,176 @@
+package goprsc
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Mutation is a POST, PUT or DELETE request made by the client which changes the state
+// of the server. Authentication requests are not mutations.
+type Mutation struct {
+	// Time is the time the request was sent.
+	Time time.Time
+
+	// Login is the login of the client user at the time of the request.
+	Login string
+
+	Method string
+
+	// Path is the request path relative to the API path (e.g. "domains/example.com").
+	Path string
+
+	// Body is the JSON encoded request body.
+	Body []byte
+
+	// StatusCode is the HTTP status of the response or 0 if no response was received.
+	StatusCode int
+
+	// Err is the error returned for the request.
+	Err error
+}
+
+// MutationObserver is called after each mutation made by a client.
+type MutationObserver func(m *Mutation)
+
+// MutationTarget identifies the object changed by a mutation.
+type MutationTarget struct {
+	Object ObjectType `json:"object"`
+	Domain string     `json:"domain"`
+
+	// Account is the username of the changed account or of the account a BCC belongs to.
+	Account string `json:"account,omitempty"`
+
+	// BccType is the type of the changed BCC.
+	BccType string `json:"bccType,omitempty"`
+
+	// Alias is the name of the changed alias.
+	Alias string `json:"alias,omitempty"`
+
+	// Email is the target address of the changed alias.
+	Email string `json:"email,omitempty"`
+}
+
+// MutationObserverOption is a client option for registering an observer of the
+// mutations made by the client.
+func MutationObserverOption(o MutationObserver) ClientOption {
+	return func(c *Client) error {
+		c.AddMutationObserver(o)
+		return nil
+	}
+}
+
+// AddMutationObserver registers an observer of the mutations made by the client. It must
+// not be called concurrently with requests.
+func (c *Client) AddMutationObserver(o MutationObserver) {
+	c.observers = append(c.observers, o)
+}
+
+func isMutation(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodPost, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	p := apiPath(req)
+	return p != authURL && !strings.HasPrefix(p, authURL+"/")
+}
+
+// apiPath returns the path of the request relative to the API path.
+func apiPath(req *http.Request) string {
+	return strings.TrimPrefix(strings.TrimPrefix(req.URL.Path, "/"), defaultAPIPath)
+}
+
+func (c *Client) newMutation(req *http.Request) *Mutation {
+	m := &Mutation{
+		Time:   time.Now(),
+		Login:  c.Login,
+		Method: req.Method,
+		Path:   apiPath(req),
+	}
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			m.Body, _ = ioutil.ReadAll(body)
+			body.Close()
+		}
+	}
+	return m
+}
+
+func (m *Mutation) complete(resp *http.Response, err error) {
+	if resp != nil {
+		m.StatusCode = resp.StatusCode
+	}
+	m.Err = err
+}
+
+// Operation returns "create", "update" or "delete" depending on the request method.
+func (m *Mutation) Operation() string {
+	switch m.Method {
+	case http.MethodPost:
+		return "create"
+	case http.MethodPut:
+		return "update"
+	default:
+		return "delete"
+	}
+}
+
+// Succeeded reports whether the request succeeded.
+func (m *Mutation) Succeeded() bool {
+	return m.Err == nil && m.StatusCode >= 200 && m.StatusCode <= 299
+}
+
+// Target returns the object changed by the mutation. Objects created with POST requests
+// are identified using the request body. The second return value is false if the path is
+// not recognized.
+func (m *Mutation) Target() (MutationTarget, bool) {
+	var body struct {
+		Name     string `json:"name"`
+		Username string `json:"username"`
+		Email    string `json:"email"`
+	}
+	json.Unmarshal(m.Body, &body)
+
+	parts := strings.Split(strings.Trim(m.Path, "/"), "/")
+	if parts[0] != domainsURL {
+		return MutationTarget{}, false
+	}
+
+	t := MutationTarget{Object: ObjectDomain}
+	switch {
+	case len(parts) == 1:
+		t.Domain = body.Name
+	case len(parts) == 2:
+		t.Domain = parts[1]
+	case parts[2] == "accounts" && len(parts) == 3:
+		t.Object, t.Domain, t.Account = ObjectAccount, parts[1], body.Username
+	case parts[2] == "accounts" && len(parts) == 4:
+		t.Object, t.Domain, t.Account = ObjectAccount, parts[1], parts[3]
+	case parts[2] == "accounts" && len(parts) == 6 && parts[4] == "bccs":
+		t.Object, t.Domain, t.Account, t.BccType = ObjectBcc, parts[1], parts[3], parts[5]
+	case parts[2] == "aliases" && len(parts) == 3:
+		t.Object, t.Domain, t.Alias, t.Email = ObjectAlias, parts[1], body.Name, body.Email
+	case parts[2] == "aliases" && len(parts) == 5:
+		t.Object, t.Domain, t.Alias, t.Email = ObjectAlias, parts[1], parts[3], parts[4]
+	default:
+		return MutationTarget{}, false
+	}
+	return t, true
+}
+
+// Key returns the key identifying the target in a ChangeEvent.
+func (t MutationTarget) Key() string {
+	switch t.Object {
+	case ObjectAccount:
+		return normalizeAddress(t.Account + "@" + t.Domain)
+	case ObjectBcc:
+		return t.BccType + " " + normalizeAddress(t.Account+"@"+t.Domain)
+	case ObjectAlias:
+		return normalizeAddress(t.Alias+"@"+t.Domain) + " -> " + normalizeAddress(t.Email)
+	default:
+		return normalizeAddress(t.Domain)
+	}
+}
@@ -0,0 +1,71 @@
+package goprsc
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestMutation_Target(t *testing.T) {
+	testCases := []struct {
+		method string
+		path   string
+		body   string
+		key    string
+		object ObjectType
+	}{
+		{http.MethodPost, "domains", `{"name":"example.com"}`, "example.com", ObjectDomain},
+		{http.MethodPut, "domains/example.com", `{"enabled":false}`, "example.com", ObjectDomain},
+		{http.MethodPost, "domains/example.com/accounts", `{"username":"john"}`, "john@example.com", ObjectAccount},
+		{http.MethodDelete, "domains/example.com/accounts/john", ``, "john@example.com", ObjectAccount},
+		{http.MethodPost, "domains/example.com/accounts/john/bccs/incoming", `{"email":"a@example.org"}`, "incoming john@example.com", ObjectBcc},
+		{http.MethodPost, "domains/example.com/aliases", `{"name":"team","email":"john@example.com"}`, "team@example.com -> john@example.com", ObjectAlias},
+		{http.MethodPut, "domains/example.com/aliases/team/john@example.com", `{}`, "team@example.com -> john@example.com", ObjectAlias},
+	}
+
+	for _, tc := range testCases {
+		m := &Mutation{Method: tc.method, Path: tc.path, Body: []byte(tc.body)}
+		target, ok := m.Target()
+		if !ok {
+			t.Errorf("%s %s: target not recognized", tc.method, tc.path)
+			continue
+		}
+		if target.Object != tc.object || target.Key() != tc.key {
+			t.Errorf("%s %s: expected: %s %s, got: %s %s", tc.method, tc.path, tc.object, tc.key, target.Object, target.Key())
+		}
+	}
+
+	if _, ok := (&Mutation{Method: http.MethodPost, Path: "auth/signin"}).Target(); ok {
+		t.Error("expected auth path not to be recognized")
+	}
+}
+
+func TestClient_MutationObserver(t *testing.T) {
+	fs := setupFake()
+	defer shutdown()
+	fs.addDomain("example.com", true)
+
+	var mutations []*Mutation
+	client.AddMutationObserver(func(m *Mutation) {
+		mutations = append(mutations, m)
+	})
+
+	if _, err := client.Domains.List(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Domains.Create("example.org"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Domains.Create("example.org"); err == nil {
+		t.Fatal("expected duplicate domain to fail")
+	}
+
+	if len(mutations) != 2 {
+		t.Fatalf("expected 2 mutations, got: %d", len(mutations))
+	}
+	if m := mutations[0]; !m.Succeeded() || m.Operation() != "create" || m.Path != "domains" || len(m.Body) == 0 {
+		t.Errorf("unexpected mutation: %#v", m)
+	}
+	if m := mutations[1]; m.Succeeded() || m.StatusCode != http.StatusConflict || m.Err == nil {
+		t.Errorf("unexpected mutation: %#v", m)
+	}
+}
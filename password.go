@@ -0,0 +1,374 @@
+package goprsc
+
+import (
+	"bufio"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"unicode"
+)
+
+const (
+	passwordLower   = "abcdefghijkmnopqrstuvwxyz"
+	passwordUpper   = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+	passwordDigits  = "23456789"
+	passwordSymbols = "!#$%&*+-=?@^_~"
+
+	// defaultGeneratedLength is the length of generated passwords unless the policy
+	// requires longer ones.
+	defaultGeneratedLength = 16
+
+	// defaultPassphraseWords is the number of words in generated passphrases unless
+	// the policy requires longer ones.
+	defaultPassphraseWords = 5
+
+	// minSimilarityDistance is the edit distance below which a password is considered
+	// similar to the username or the domain.
+	minSimilarityDistance = 3
+
+	maxGenerateAttempts = 100
+)
+
+// PasswordPolicy describes the requirements for account passwords. The zero value accepts
+// any password. Use PasswordPolicyOption to check passwords before creating accounts or
+// changing their passwords.
+type PasswordPolicy struct {
+	// MinLength and MaxLength limit the number of characters in a password. Zero
+	// MaxLength means no limit.
+	MinLength int
+	MaxLength int
+
+	// RequireUpper, RequireLower, RequireDigit and RequireSymbol require at least one
+	// character of the respective class.
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+
+	// MinClasses is the minimum number of different character classes (upper case,
+	// lower case, digits and symbols) in a password.
+	MinClasses int
+
+	// DisallowSimilar rejects passwords containing the username or the domain name or
+	// which differ from them in only a few characters.
+	DisallowSimilar bool
+
+	// Wordlist is the list of words for generated passphrases (defaults to a built-in
+	// list of 256 words).
+	Wordlist []string
+
+	banned map[string]bool
+}
+
+// DefaultPasswordPolicy returns a policy requiring passwords of at least 12 characters
+// from three character classes, which are not similar to the username or the domain.
+func DefaultPasswordPolicy() *PasswordPolicy {
+	return &PasswordPolicy{
+		MinLength:       12,
+		MinClasses:      3,
+		DisallowSimilar: true,
+	}
+}
+
+// PasswordPolicyOption is a client option for checking passwords against p before
+// creating accounts or changing their passwords.
+func PasswordPolicyOption(p *PasswordPolicy) ClientOption {
+	return func(c *Client) error {
+		c.passwordPolicy = p
+		return nil
+	}
+}
+
+// PasswordPolicyError describes why a password was rejected by a policy.
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "password rejected: " + strings.Join(e.Violations, ", ")
+}
+
+// Ban rejects the given passwords. Banned passwords are compared case-insensitively.
+func (p *PasswordPolicy) Ban(passwords ...string) {
+	if p.banned == nil {
+		p.banned = make(map[string]bool)
+	}
+	for _, password := range passwords {
+		p.banned[strings.ToLower(password)] = true
+	}
+}
+
+// LoadBannedPasswords bans the passwords listed in the file at path, one per line. Empty
+// lines and lines starting with # are ignored.
+func (p *PasswordPolicy) LoadBannedPasswords(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.Ban(line)
+	}
+	return s.Err()
+}
+
+// Check returns a *PasswordPolicyError if password doesn't satisfy the policy for the
+// account with the given username in the given domain.
+func (p *PasswordPolicy) Check(domain, username, password string) error {
+	var violations []string
+	n := len([]rune(password))
+	if n < p.MinLength {
+		violations = append(violations, fmt.Sprintf("shorter than %d characters", p.MinLength))
+	}
+	if p.MaxLength > 0 && n > p.MaxLength {
+		violations = append(violations, fmt.Sprintf("longer than %d characters", p.MaxLength))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	classes := 0
+	for _, c := range []struct {
+		present, required bool
+		name              string
+	}{
+		{upper, p.RequireUpper, "upper case letter"},
+		{lower, p.RequireLower, "lower case letter"},
+		{digit, p.RequireDigit, "digit"},
+		{symbol, p.RequireSymbol, "symbol"},
+	} {
+		if c.present {
+			classes++
+		} else if c.required {
+			violations = append(violations, "no "+c.name)
+		}
+	}
+	if classes < p.MinClasses {
+		violations = append(violations, fmt.Sprintf("fewer than %d character classes", p.MinClasses))
+	}
+
+	if p.banned[strings.ToLower(password)] {
+		violations = append(violations, "commonly used password")
+	}
+	if p.DisallowSimilar {
+		if s := similarTo(password, domain, username); len(s) > 0 {
+			violations = append(violations, "similar to the "+s)
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// similarTo returns "username" or "domain" if password is similar to either of them.
+func similarTo(password, domain, username string) string {
+	password = strings.ToLower(password)
+	name := domain
+	if i := strings.Index(name, "."); i > 0 {
+		name = name[:i]
+	}
+	for _, c := range []struct{ kind, value string }{
+		{"username", username},
+		{"domain", name},
+		{"domain", domain},
+	} {
+		v := strings.ToLower(c.value)
+		if len(v) < minSimilarityDistance {
+			continue
+		}
+		if strings.Contains(password, v) || strings.Contains(password, reverse(v)) ||
+			editDistance(password, v) < minSimilarityDistance {
+			return c.kind
+		}
+	}
+	return ""
+}
+
+func reverse(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// Generate returns a random password satisfying the policy for the account with the
+// given username in the given domain. Generated passwords contain characters of all
+// classes, omitting ones which are easy to confuse, such as 0 and O.
+func (p *PasswordPolicy) Generate(domain, username string) (string, error) {
+	length := defaultGeneratedLength
+	if p.MinLength > length {
+		length = p.MinLength
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		length = p.MaxLength
+	}
+	classes := []string{passwordLower, passwordUpper, passwordDigits, passwordSymbols}
+	if length < len(classes) {
+		return "", fmt.Errorf("can't generate passwords shorter than %d characters", len(classes))
+	}
+	all := strings.Join(classes, "")
+
+	return p.generate(domain, username, func() (string, error) {
+		password := make([]byte, length)
+		for i := range password {
+			set := all
+			if i < len(classes) {
+				set = classes[i]
+			}
+			c, err := randomChoice(len(set))
+			if err != nil {
+				return "", err
+			}
+			password[i] = set[c]
+		}
+		// Move the characters guaranteeing the classes to random positions.
+		for i := len(password) - 1; i > 0; i-- {
+			j, err := randomChoice(i + 1)
+			if err != nil {
+				return "", err
+			}
+			password[i], password[j] = password[j], password[i]
+		}
+		return string(password), nil
+	})
+}
+
+// GeneratePassphrase returns a random passphrase of at least the given number of words
+// satisfying the policy. Words are capitalized and separated by dashes and a random
+// digit is appended to one of them. If words is zero, a default of 5 words is used.
+// More words are added if the passphrase would be shorter than the policy requires.
+func (p *PasswordPolicy) GeneratePassphrase(domain, username string, words int) (string, error) {
+	if words <= 0 {
+		words = defaultPassphraseWords
+	}
+	wordlist := p.Wordlist
+	if len(wordlist) == 0 {
+		wordlist = defaultWordlist
+	}
+
+	return p.generate(domain, username, func() (string, error) {
+		var parts []string
+		length := 0
+		for len(parts) < words || length < p.MinLength {
+			i, err := randomChoice(len(wordlist))
+			if err != nil {
+				return "", err
+			}
+			w := []rune(wordlist[i])
+			w[0] = unicode.ToUpper(w[0])
+			parts = append(parts, string(w))
+			length += len(w) + 1
+		}
+		i, err := randomChoice(len(parts))
+		if err != nil {
+			return "", err
+		}
+		d, err := randomChoice(10)
+		if err != nil {
+			return "", err
+		}
+		parts[i] += fmt.Sprint(d)
+		return strings.Join(parts, "-"), nil
+	})
+}
+
+// generate calls fn until it returns a password satisfying the policy.
+func (p *PasswordPolicy) generate(domain, username string, fn func() (string, error)) (string, error) {
+	var err error
+	for i := 0; i < maxGenerateAttempts; i++ {
+		var password string
+		if password, err = fn(); err != nil {
+			return "", err
+		}
+		if err = p.Check(domain, username, password); err == nil {
+			return password, nil
+		}
+	}
+	return "", fmt.Errorf("can't generate a password satisfying the policy: %v", err)
+}
+
+// randomChoice returns a uniformly distributed random number in [0, n).
+func randomChoice(n int) (int, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(i.Int64()), nil
+}
+
+// checkPassword checks password against the password policy of the client, if any.
+func (c *Client) checkPassword(domain, username, password string) error {
+	if c.passwordPolicy == nil {
+		return nil
+	}
+	return c.passwordPolicy.Check(domain, username, password)
+}
+
+// checkUpdatePassword checks the password of an account update request against the
+// password policy of the client, if any.
+func (c *Client) checkUpdatePassword(domain, username string, ur *AccountUpdateRequest) error {
+	if c.passwordPolicy == nil || ur == nil || len(ur.Password) == 0 {
+		return nil
+	}
+	if ur.ConfirmPassword != ur.Password {
+		return errors.New("password and confirmation don't match")
+	}
+	if len(ur.Username) > 0 {
+		username = ur.Username
+	}
+	return c.checkPassword(domain, username, ur.Password)
+}
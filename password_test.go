@@ -0,0 +1,120 @@
+package goprsc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicy_Check(t *testing.T) {
+	p := &PasswordPolicy{MinLength: 10, MaxLength: 20, RequireDigit: true, MinClasses: 3, DisallowSimilar: true}
+
+	dir, err := ioutil.TempDir("", "goprsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	banned := filepath.Join(dir, "banned.txt")
+	if err := ioutil.WriteFile(banned, []byte("# common passwords\nPassword123!\n\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.LoadBannedPasswords(banned); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		password  string
+		violation string
+	}{
+		{"Kx7#mPq2vLw9", ""},
+		{"Kx7#mP", "shorter than 10 characters"},
+		{"Kx7#mPq2vLw9Kx7#mPq2vLw9", "longer than 20 characters"},
+		{"Kx#mPqvLwzz", "no digit"},
+		{"kx7mpq2vlw9", "fewer than 3 character classes"},
+		{"password123!", "commonly used password"},
+		{"John.Smith-2017", "similar to the username"},
+		{"htims.nhoj#2017", "similar to the username"},
+		{"Example#2017!", "similar to the domain"},
+	}
+	for _, tc := range testCases {
+		err := p.Check("example.com", "john.smith", tc.password)
+		if len(tc.violation) == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tc.password, err)
+			}
+			continue
+		}
+		pe, ok := err.(*PasswordPolicyError)
+		if !ok {
+			t.Errorf("%s: expected *PasswordPolicyError, got: %v", tc.password, err)
+			continue
+		}
+		if !strings.Contains(strings.Join(pe.Violations, ", "), tc.violation) {
+			t.Errorf("%s: expected violation %q, got: %v", tc.password, tc.violation, pe.Violations)
+		}
+	}
+}
+
+func TestPasswordPolicy_Generate(t *testing.T) {
+	p := DefaultPasswordPolicy()
+	p.RequireSymbol = true
+	p.MinLength = 20
+
+	seen := make(map[string]bool)
+	for i := 0; i < 50; i++ {
+		password, err := p.Generate("example.com", "john")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(password) != 20 || seen[password] {
+			t.Fatalf("unexpected password: %s", password)
+		}
+		seen[password] = true
+
+		passphrase, err := p.GeneratePassphrase("example.com", "john", 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Check("example.com", "john", passphrase); err != nil {
+			t.Fatalf("%s: %v", passphrase, err)
+		}
+		if words := strings.Split(passphrase, "-"); len(words) < 4 {
+			t.Fatalf("expected at least 4 words, got: %s", passphrase)
+		}
+	}
+
+	if _, err := (&PasswordPolicy{MaxLength: 3}).Generate("", ""); err == nil {
+		t.Fatal("expected an unsatisfiable policy to fail")
+	}
+}
+
+func TestAccountService_PasswordPolicy(t *testing.T) {
+	fs := setupFake()
+	defer shutdown()
+	fs.addDomain("example.com", true)
+	fs.addAccount("example.com", "john", true)
+
+	if err := PasswordPolicyOption(DefaultPasswordPolicy())(client); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Accounts.Create("example.com", "jane", "secret"); err == nil {
+		t.Fatal("expected weak password to be rejected")
+	}
+	ur := &AccountUpdateRequest{Password: "Kx7#mPq2vLw9", ConfirmPassword: "Kx7#mPq2vLw8", Enabled: true}
+	if err := client.Accounts.Update("example.com", "john", ur); err == nil {
+		t.Fatal("expected mismatching confirmation to be rejected")
+	}
+	if len(fs.requests) != 0 {
+		t.Fatalf("expected rejected passwords not to be sent, got: %v", fs.requests)
+	}
+
+	if err := client.Accounts.Create("example.com", "jane", "Kx7#mPq2vLw9"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Accounts.Update("example.com", "john", &AccountUpdateRequest{Enabled: false}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,274 @@
+package goprsc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DomainRenamePlan describes the changes performed when renaming a domain.
+type DomainRenamePlan struct {
+	OldName string
+	NewName string
+
+	// Accounts, Aliases and Bccs are the objects in the domain which must follow it
+	// under the new name.
+	Accounts []Account
+	Aliases  []Alias
+	Bccs     []AccountBcc
+
+	// AliasRewrites are the aliases in any domain whose target address is in the old domain.
+	AliasRewrites []AliasRewrite
+
+	// BccRewrites are the BCCs in any domain whose address is in the old domain.
+	BccRewrites []BccRewrite
+}
+
+// AccountBcc is a BCC together with the account it belongs to.
+type AccountBcc struct {
+	Domain  string
+	Account string
+
+	// Type is either IncomingBccType or OutgoingBccType.
+	Type string
+
+	Bcc Bcc
+}
+
+// AliasRewrite is a change of the target address of an alias.
+type AliasRewrite struct {
+	Domain string
+	Alias  Alias
+	Email  string
+}
+
+// BccRewrite is a change of the address of a BCC.
+type BccRewrite struct {
+	AccountBcc
+	Email string
+}
+
+// PlanRename collects the objects affected by renaming the domain oldName to newName
+// without changing anything on the server.
+func (s *DomainService) PlanRename(oldName, newName string) (*DomainRenamePlan, error) {
+	if _, err := s.Get(oldName); err != nil {
+		return nil, err
+	}
+	if _, err := s.Get(newName); err == nil {
+		return nil, fmt.Errorf("domain %s already exists", newName)
+	} else if !isNotFound(err) {
+		return nil, err
+	}
+
+	domains, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &DomainRenamePlan{
+		OldName: oldName,
+		NewName: newName,
+	}
+	for _, d := range domains {
+		accounts, err := s.client.Accounts.List(d.Name)
+		if err != nil {
+			return nil, err
+		}
+		aliases, err := s.client.Aliases.List(d.Name)
+		if err != nil {
+			return nil, err
+		}
+		bccs, err := s.client.listAccountBccs(d.Name, accounts, bccTypes...)
+		if err != nil {
+			return nil, err
+		}
+
+		if d.Name == oldName {
+			plan.Accounts = accounts
+			plan.Aliases = aliases
+			plan.Bccs = bccs
+		}
+		for _, a := range aliases {
+			if email, ok := replaceAddressDomain(a.Email, oldName, newName); ok {
+				plan.AliasRewrites = append(plan.AliasRewrites, AliasRewrite{Domain: d.Name, Alias: a, Email: email})
+			}
+		}
+		for _, b := range bccs {
+			if email, ok := replaceAddressDomain(b.Bcc.Email, oldName, newName); ok {
+				plan.BccRewrites = append(plan.BccRewrites, BccRewrite{AccountBcc: b, Email: email})
+			}
+		}
+	}
+
+	return plan, nil
+}
+
+// Rename renames the domain oldName to newName, verifies that all accounts, aliases and BCCs
+// followed it and rewrites alias targets and BCC addresses referencing the old domain.
+// All changes are rolled back if any step fails.
+func (s *DomainService) Rename(oldName, newName string) (*DomainRenamePlan, error) {
+	plan, err := s.PlanRename(oldName, newName)
+	if err != nil {
+		return nil, err
+	}
+	return plan, s.ApplyRename(plan)
+}
+
+// ApplyRename performs the changes described by plan. All changes are rolled back if any
+// step fails.
+func (s *DomainService) ApplyRename(plan *DomainRenamePlan) error {
+	var undo undoStack
+	fail := func(err error) error {
+		if rerr := undo.rollback(); rerr != nil {
+			return fmt.Errorf("rename %s to %s: %v (rollback failed: %v)", plan.OldName, plan.NewName, err, rerr)
+		}
+		return fmt.Errorf("rename %s to %s: %v", plan.OldName, plan.NewName, err)
+	}
+
+	d, err := s.Get(plan.OldName)
+	if err != nil {
+		return fail(err)
+	}
+	if err := s.Update(plan.OldName, &DomainUpdateRequest{Name: plan.NewName, Enabled: d.Enabled}); err != nil {
+		return fail(err)
+	}
+	undo.push(func() error {
+		return s.Update(plan.NewName, &DomainUpdateRequest{Name: plan.OldName, Enabled: d.Enabled})
+	})
+
+	if err := s.verifyRename(plan); err != nil {
+		return fail(err)
+	}
+
+	domain := func(name string) string {
+		if name == plan.OldName {
+			return plan.NewName
+		}
+		return name
+	}
+
+	for _, rw := range plan.AliasRewrites {
+		rw := rw
+		dn := domain(rw.Domain)
+		ur := &AliasUpdateRequest{Name: rw.Alias.Name, Email: rw.Email, Enabled: rw.Alias.Enabled}
+		if err := s.client.Aliases.Update(dn, rw.Alias.Name, rw.Alias.Email, ur); err != nil {
+			return fail(err)
+		}
+		undo.push(func() error {
+			ur := &AliasUpdateRequest{Name: rw.Alias.Name, Email: rw.Alias.Email, Enabled: rw.Alias.Enabled}
+			return s.client.Aliases.Update(dn, rw.Alias.Name, rw.Email, ur)
+		})
+	}
+
+	for _, rw := range plan.BccRewrites {
+		rw := rw
+		dn := domain(rw.Domain)
+		bccs := s.client.bccService(rw.Type)
+		if err := bccs.Update(dn, rw.Account, &BccUpdateRequest{Email: rw.Email, Enabled: rw.Bcc.Enabled}); err != nil {
+			return fail(err)
+		}
+		undo.push(func() error {
+			return bccs.Update(dn, rw.Account, &BccUpdateRequest{Email: rw.Bcc.Email, Enabled: rw.Bcc.Enabled})
+		})
+	}
+
+	return nil
+}
+
+// verifyRename checks that the accounts, aliases and BCCs of the renamed domain are
+// available under the new name.
+func (s *DomainService) verifyRename(plan *DomainRenamePlan) error {
+	accounts, err := s.client.Accounts.List(plan.NewName)
+	if err != nil {
+		return err
+	}
+	usernames := make(map[string]bool, len(accounts))
+	for _, a := range accounts {
+		usernames[a.Username] = true
+	}
+	for _, a := range plan.Accounts {
+		if !usernames[a.Username] {
+			return fmt.Errorf("account %s missing after rename", a.Username)
+		}
+	}
+
+	aliases, err := s.client.Aliases.List(plan.NewName)
+	if err != nil {
+		return err
+	}
+	targets := make(map[string]bool, len(aliases))
+	for _, a := range aliases {
+		targets[a.Name+" "+a.Email] = true
+	}
+	for _, a := range plan.Aliases {
+		if !targets[a.Name+" "+a.Email] {
+			return fmt.Errorf("alias %s -> %s missing after rename", a.Name, a.Email)
+		}
+	}
+
+	for _, b := range plan.Bccs {
+		bcc, err := s.client.bccService(b.Type).Get(plan.NewName, b.Account)
+		if err != nil {
+			return fmt.Errorf("%s bcc of %s: %v", b.Type, b.Account, err)
+		}
+		if bcc.Email != b.Bcc.Email {
+			return fmt.Errorf("%s bcc of %s changed to %s after rename", b.Type, b.Account, bcc.Email)
+		}
+	}
+
+	return nil
+}
+
+// listAccountBccs fetches the BCCs of the given types of the given accounts. Accounts
+// without a BCC of a given type are skipped.
+func (c *Client) listAccountBccs(domain string, accounts []Account, types ...string) ([]AccountBcc, error) {
+	var bccs []AccountBcc
+	for _, a := range accounts {
+		for _, t := range types {
+			bcc, err := c.bccService(t).Get(domain, a.Username)
+			if isNotFound(err) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			bccs = append(bccs, AccountBcc{Domain: domain, Account: a.Username, Type: t, Bcc: *bcc})
+		}
+	}
+	return bccs, nil
+}
+
+// splitAddress splits an email address into its local part and domain.
+func splitAddress(email string) (local, domain string) {
+	i := strings.LastIndex(email, "@")
+	if i < 0 {
+		return email, ""
+	}
+	return email[:i], email[i+1:]
+}
+
+// replaceAddressDomain replaces the domain of email with newDomain if it equals oldDomain.
+func replaceAddressDomain(email, oldDomain, newDomain string) (string, bool) {
+	local, domain := splitAddress(email)
+	if !strings.EqualFold(domain, oldDomain) {
+		return email, false
+	}
+	return local + "@" + newDomain, true
+}
+
+// undoStack records compensating actions for changes which have already been applied.
+type undoStack []func() error
+
+func (u *undoStack) push(f func() error) {
+	*u = append(*u, f)
+}
+
+// rollback runs the recorded actions in reverse order. All actions are attempted and the
+// first error is returned.
+func (u undoStack) rollback() error {
+	var first error
+	for i := len(u) - 1; i >= 0; i-- {
+		if err := u[i](); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,79 @@
+package goprsc
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func setupRename() *fakeServer {
+	fs := setupFake()
+	fs.addDomain("example.com", true)
+	fs.addDomain("example.org", true)
+	fs.addAccount("example.com", "info", true)
+	fs.addAccount("example.org", "admin", true)
+	fs.addAlias("example.com", "contact", "info@example.com", true)
+	fs.addAlias("example.org", "support", "info@example.com", true)
+	fs.addBcc("example.com", "info", IncomingBccType, "archive@example.org", true)
+	fs.addBcc("example.org", "admin", OutgoingBccType, "info@example.com", true)
+	return fs
+}
+
+func TestDomain_Rename(t *testing.T) {
+	fs := setupRename()
+	defer shutdown()
+
+	plan, err := client.Domains.Rename("example.com", "example.net")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(plan.AliasRewrites) != 2 || len(plan.BccRewrites) != 1 {
+		t.Fatalf("expected 2 alias and 1 bcc rewrites, got: %d, %d", len(plan.AliasRewrites), len(plan.BccRewrites))
+	}
+	if fs.domain("example.com") != nil || fs.domain("example.net") == nil {
+		t.Fatal("domain was not renamed")
+	}
+	if fs.alias("example.net", "contact", "info@example.net") == nil {
+		t.Fatal("alias in renamed domain was not rewritten")
+	}
+	if fs.alias("example.org", "support", "info@example.net") == nil {
+		t.Fatal("alias in other domain was not rewritten")
+	}
+	admin := fs.account("example.org", "admin")
+	if email := fs.bccs[bccKey(admin.ID, OutgoingBccType)].Email; email != "info@example.net" {
+		t.Fatalf("expected: %v, got: %v", "info@example.net", email)
+	}
+}
+
+func TestDomain_RenameExisting(t *testing.T) {
+	setupRename()
+	defer shutdown()
+
+	if _, err := client.Domains.Rename("example.com", "example.org"); err == nil {
+		t.Fatal("expected error when renaming to an existing domain")
+	}
+}
+
+func TestDomain_RenameRollback(t *testing.T) {
+	fs := setupRename()
+	defer shutdown()
+
+	fs.fail = func(r *http.Request) bool {
+		return r.Method == http.MethodPut && strings.Contains(r.URL.Path, "/bccs/")
+	}
+
+	if _, err := client.Domains.Rename("example.com", "example.net"); err == nil {
+		t.Fatal("expected rename to fail")
+	}
+
+	if fs.domain("example.com") == nil || fs.domain("example.net") != nil {
+		t.Fatal("domain rename was not rolled back")
+	}
+	if fs.alias("example.com", "contact", "info@example.com") == nil {
+		t.Fatal("alias rewrite in renamed domain was not rolled back")
+	}
+	if fs.alias("example.org", "support", "info@example.com") == nil {
+		t.Fatal("alias rewrite in other domain was not rolled back")
+	}
+}
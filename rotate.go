@@ -0,0 +1,331 @@
+package goprsc
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	manifestVersion    = 1
+	manifestKDF        = "pbkdf2-sha256"
+	manifestIterations = 200000
+)
+
+// RotationOptions configures the rotation of the passwords in a domain.
+type RotationOptions struct {
+	// Filter selects the accounts whose passwords are rotated. Nil selects all accounts.
+	Filter func(a *Account) bool
+
+	// Policy is used to generate the new passwords. Defaults to the password policy of
+	// the client or DefaultPasswordPolicy.
+	Policy *PasswordPolicy
+
+	// Passphrase generates passphrases instead of random character passwords.
+	Passphrase bool
+
+	// Manifest is the path of the encrypted credentials manifest. It is written after
+	// each rotated account and a rotation interrupted by an error can be resumed by
+	// running it again with the same manifest and passphrase.
+	Manifest string
+
+	// ManifestPassphrase is the passphrase from which the manifest encryption key is
+	// derived.
+	ManifestPassphrase string
+}
+
+// RotationManifest lists the new credentials of the accounts in a domain.
+type RotationManifest struct {
+	Domain      string               `json:"domain"`
+	Started     time.Time            `json:"started"`
+	Credentials []RotationCredential `json:"credentials"`
+}
+
+// RotationCredential is the new password of an account.
+type RotationCredential struct {
+	Address  string `json:"address"`
+	Password string `json:"password"`
+
+	// Pending is set while the password is being sent to the server. A pending credential
+	// left by an interrupted rotation may or may not be the password of the account; it
+	// is rotated again by a resumed run.
+	Pending bool `json:"pending,omitempty"`
+
+	Rotated time.Time `json:"rotated"`
+}
+
+// RotationFailure describes an account whose password couldn't be rotated.
+type RotationFailure struct {
+	Address string
+	Err     error
+}
+
+// RotationResult describes the outcome of a password rotation.
+type RotationResult struct {
+	// Rotated lists the addresses of the accounts whose passwords were rotated.
+	Rotated []string
+
+	// Skipped lists the addresses of the accounts rotated by a previous run.
+	Skipped []string
+
+	// Failed lists the accounts whose passwords couldn't be rotated.
+	Failed []RotationFailure
+}
+
+// encryptedManifest is the format of the manifest file. The manifest is encrypted with
+// AES-256-GCM using a key derived from the passphrase with PBKDF2.
+type encryptedManifest struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// RotatePasswords assigns new passwords to the accounts in domain selected by opts.Filter
+// and writes them to the encrypted manifest. Failures are recorded in the result and
+// don't stop the rotation of the remaining accounts; RotatePasswords returns an error if
+// any account failed. Each new password is saved in the manifest as pending before it is
+// sent to the server. Running it again with the same manifest retries failed and pending
+// accounts and skips the ones already rotated.
+func (s *AccountService) RotatePasswords(domain string, opts *RotationOptions) (*RotationResult, error) {
+	if opts == nil || len(opts.Manifest) == 0 || len(opts.ManifestPassphrase) == 0 {
+		return nil, errors.New("rotation requires a manifest path and passphrase")
+	}
+	policy := opts.Policy
+	if policy == nil {
+		policy = s.client.passwordPolicy
+	}
+	if policy == nil {
+		policy = DefaultPasswordPolicy()
+	}
+
+	m, key, err := openRotationManifest(opts.Manifest, opts.ManifestPassphrase)
+	if err != nil {
+		return nil, err
+	}
+	if m.Started.IsZero() {
+		m.Domain, m.Started = domain, time.Now().UTC()
+	} else if !strings.EqualFold(m.Domain, domain) {
+		return nil, fmt.Errorf("manifest %s belongs to domain %s", opts.Manifest, m.Domain)
+	}
+	done := make(map[string]bool)
+	for _, c := range m.Credentials {
+		done[c.Address] = !c.Pending
+	}
+
+	accounts, err := s.List(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RotationResult{}
+	for i := range accounts {
+		a := &accounts[i]
+		if opts.Filter != nil && !opts.Filter(a) {
+			continue
+		}
+		address := normalizeAddress(a.Username + "@" + domain)
+		if done[address] {
+			result.Skipped = append(result.Skipped, address)
+			continue
+		}
+
+		if err := s.rotatePassword(domain, a, policy, opts.Passphrase, m, key, opts.Manifest); err != nil {
+			result.Failed = append(result.Failed, RotationFailure{Address: address, Err: err})
+			continue
+		}
+		result.Rotated = append(result.Rotated, address)
+	}
+
+	if len(result.Failed) > 0 {
+		return result, fmt.Errorf("rotation failed for %d of %d accounts", len(result.Failed),
+			len(result.Failed)+len(result.Rotated)+len(result.Skipped))
+	}
+	return result, nil
+}
+
+func (s *AccountService) rotatePassword(domain string, a *Account, policy *PasswordPolicy, passphrase bool,
+	m *RotationManifest, key *manifestKey, path string) error {
+	var password string
+	var err error
+	if passphrase {
+		password, err = policy.GeneratePassphrase(domain, a.Username, 0)
+	} else {
+		password, err = policy.Generate(domain, a.Username)
+	}
+	if err != nil {
+		return err
+	}
+
+	// The password is saved as pending before it is sent, so that it isn't lost if the
+	// manifest can't be written after the update.
+	address := normalizeAddress(a.Username + "@" + domain)
+	c := m.credential(address)
+	*c = RotationCredential{Address: address, Password: password, Pending: true}
+	if err := writeRotationManifest(path, m, key); err != nil {
+		return err
+	}
+
+	ur := &AccountUpdateRequest{Password: password, ConfirmPassword: password, Enabled: a.Enabled}
+	if err := s.Update(domain, a.Username, ur); err != nil {
+		return err
+	}
+	c.Pending, c.Rotated = false, time.Now().UTC()
+	if err := writeRotationManifest(path, m, key); err != nil {
+		return fmt.Errorf("password changed, but the manifest lists it as pending: %v", err)
+	}
+	return nil
+}
+
+// credential returns the credential of address in the manifest, adding it if missing.
+func (m *RotationManifest) credential(address string) *RotationCredential {
+	for i := range m.Credentials {
+		if m.Credentials[i].Address == address {
+			return &m.Credentials[i]
+		}
+	}
+	m.Credentials = append(m.Credentials, RotationCredential{Address: address})
+	return &m.Credentials[len(m.Credentials)-1]
+}
+
+// manifestKey is the encryption key of a manifest and the parameters it was derived with.
+type manifestKey struct {
+	salt       []byte
+	iterations int
+	key        []byte
+}
+
+// ReadRotationManifest decrypts the credentials manifest at path.
+func ReadRotationManifest(path, passphrase string) (*RotationManifest, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m, _, err := decryptRotationManifest(data, passphrase)
+	return m, err
+}
+
+// openRotationManifest reads the manifest at path or returns an empty one with a new key
+// if it doesn't exist.
+func openRotationManifest(path, passphrase string) (*RotationManifest, *manifestKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, nil, err
+		}
+		key := &manifestKey{
+			salt:       salt,
+			iterations: manifestIterations,
+			key:        pbkdf2SHA256([]byte(passphrase), salt, manifestIterations, 32),
+		}
+		return &RotationManifest{}, key, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return decryptRotationManifest(data, passphrase)
+}
+
+func decryptRotationManifest(data []byte, passphrase string) (*RotationManifest, *manifestKey, error) {
+	var em encryptedManifest
+	if err := json.Unmarshal(data, &em); err != nil {
+		return nil, nil, err
+	}
+	if em.Version != manifestVersion || em.KDF != manifestKDF {
+		return nil, nil, fmt.Errorf("unsupported manifest version %d (%s)", em.Version, em.KDF)
+	}
+	if em.Iterations <= 0 {
+		return nil, nil, fmt.Errorf("invalid manifest iteration count %d", em.Iterations)
+	}
+	key := &manifestKey{
+		salt:       em.Salt,
+		iterations: em.Iterations,
+		key:        pbkdf2SHA256([]byte(passphrase), em.Salt, em.Iterations, 32),
+	}
+	gcm, err := newManifestCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	plaintext, err := gcm.Open(nil, em.Nonce, em.Ciphertext, nil)
+	if err != nil {
+		return nil, nil, errors.New("can't decrypt manifest: wrong passphrase or corrupted file")
+	}
+	var m RotationManifest
+	if err := json.Unmarshal(plaintext, &m); err != nil {
+		return nil, nil, err
+	}
+	return &m, key, nil
+}
+
+func writeRotationManifest(path string, m *RotationManifest, key *manifestKey) error {
+	plaintext, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	gcm, err := newManifestCipher(key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	data, err := json.Marshal(&encryptedManifest{
+		Version:    manifestVersion,
+		KDF:        manifestKDF,
+		Iterations: key.iterations,
+		Salt:       key.salt,
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, plaintext, nil),
+	})
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0600)
+}
+
+func newManifestCipher(key *manifestKey) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2SHA256 derives a key of keyLen bytes from password as specified in RFC 8018.
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	var key []byte
+	for block := uint32(1); len(key) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		var n [4]byte
+		binary.BigEndian.PutUint32(n[:], block)
+		prf.Write(n[:])
+		u := prf.Sum(nil)
+		t := append([]byte{}, u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
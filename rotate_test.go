@@ -0,0 +1,114 @@
+package goprsc
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAccountService_RotatePasswords(t *testing.T) {
+	fs := setupFake()
+	defer shutdown()
+	fs.addDomain("example.com", true)
+	for _, u := range []string{"john", "jane", "bob", "service"} {
+		fs.addAccount("example.com", u, u != "bob")
+	}
+
+	dir, err := ioutil.TempDir("", "goprsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	opts := &RotationOptions{
+		Filter:             func(a *Account) bool { return a.Username != "service" },
+		Manifest:           filepath.Join(dir, "manifest.json"),
+		ManifestPassphrase: "correct horse",
+	}
+	pending := func(address string) *RotationCredential {
+		m, err := ReadRotationManifest(opts.Manifest, opts.ManifestPassphrase)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range m.Credentials {
+			if c.Address == address && c.Pending {
+				return &c
+			}
+		}
+		return nil
+	}
+	fs.fail = func(r *http.Request) bool {
+		// The new password is saved before it is sent to the server.
+		if r.Method == http.MethodPut && strings.HasSuffix(r.URL.Path, "/john") && pending("john@example.com") == nil {
+			t.Error("expected pending credential in the manifest before the update")
+		}
+		return r.Method == http.MethodPut && strings.HasSuffix(r.URL.Path, "/jane")
+	}
+
+	result, err := client.Accounts.RotatePasswords("example.com", opts)
+	if err == nil {
+		t.Fatal("expected the failed account to be reported")
+	}
+	if len(result.Rotated) != 2 || len(result.Failed) != 1 || result.Failed[0].Address != "jane@example.com" {
+		t.Fatalf("unexpected result: %#v", result)
+	}
+	if fs.account("example.com", "bob").Enabled {
+		t.Error("expected disabled account to stay disabled")
+	}
+
+	data, err := ioutil.ReadFile(opts.Manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), fs.passwords["john@example.com"]) {
+		t.Fatal("manifest is not encrypted")
+	}
+	if _, err := ReadRotationManifest(opts.Manifest, "wrong"); err == nil {
+		t.Fatal("expected wrong passphrase to fail")
+	}
+	if pending("jane@example.com") == nil || pending("john@example.com") != nil {
+		t.Fatal("expected only the failed account to be pending")
+	}
+
+	fs.fail = nil
+	result, err = client.Accounts.RotatePasswords("example.com", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Rotated) != 1 || result.Rotated[0] != "jane@example.com" || len(result.Skipped) != 2 {
+		t.Fatalf("unexpected resumed result: %#v", result)
+	}
+
+	m, err := ReadRotationManifest(opts.Manifest, opts.ManifestPassphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Domain != "example.com" || len(m.Credentials) != 3 {
+		t.Fatalf("unexpected manifest: %#v", m)
+	}
+	for _, c := range m.Credentials {
+		if c.Pending || fs.passwords[c.Address] != c.Password {
+			t.Errorf("%s: manifest password doesn't match the server", c.Address)
+		}
+		if err := DefaultPasswordPolicy().Check("example.com", strings.Split(c.Address, "@")[0], c.Password); err != nil {
+			t.Errorf("%s: %v", c.Address, err)
+		}
+	}
+	if _, ok := fs.passwords["service@example.com"]; ok {
+		t.Error("expected filtered account not to be rotated")
+	}
+}
+
+func TestPBKDF2SHA256(t *testing.T) {
+	// Test vector from RFC 7914.
+	expected := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+		"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"
+	key := pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1, 64)
+	if hex.EncodeToString(key) != expected {
+		t.Fatalf("unexpected key: %x", key)
+	}
+}
@@ -0,0 +1,441 @@
+package goprsc
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoRoute is returned by a Router for domains which aren't mapped to a server.
+var ErrNoRoute = errors.New("no server for domain")
+
+// Router dispatches requests to several independent servers, each serving a part of the
+// domains. Domains are mapped to servers with static routes or by discovering the domains
+// of each server. Requests for unmapped domains trigger a new discovery, at most once per
+// DiscoveryInterval, once Discover was called.
+type Router struct {
+	// DefaultServer, if set, is the server on which new domains without a route are
+	// created.
+	DefaultServer string
+
+	// DiscoveryInterval is the minimum time between discoveries triggered by unmapped
+	// domains (defaults to 30 seconds).
+	DiscoveryInterval time.Duration
+
+	// Domains dispatches domain requests and merges the domains of all servers.
+	Domains *RouterDomainService
+
+	// Accounts dispatches account requests.
+	Accounts *RouterAccountService
+
+	// Aliases dispatches alias requests.
+	Aliases *RouterAliasService
+
+	// OutputBccs dispatches outgoing BCC requests.
+	OutputBccs *RouterBccService
+
+	// InputBccs dispatches incoming BCC requests.
+	InputBccs *RouterBccService
+
+	clients map[string]*Client
+	names   []string
+
+	mu           sync.Mutex
+	static       map[string]string
+	discovered   map[string]string
+	discoveredAt time.Time
+}
+
+type routerService struct {
+	router *Router
+}
+
+// RouterDomainService dispatches domain requests to the servers of a Router.
+type RouterDomainService routerService
+
+// RouterAccountService dispatches account requests to the servers of a Router.
+type RouterAccountService routerService
+
+// RouterAliasService dispatches alias requests to the servers of a Router.
+type RouterAliasService routerService
+
+// RouterBccService dispatches BCC requests of one type to the servers of a Router. It
+// implements BccService and BccLister.
+type RouterBccService struct {
+	router  *Router
+	bccType string
+}
+
+// NewRouter returns a router for the given clients, keyed by server name.
+func NewRouter(clients map[string]*Client) *Router {
+	r := &Router{
+		clients:    make(map[string]*Client),
+		static:     make(map[string]string),
+		discovered: make(map[string]string),
+	}
+	for name, c := range clients {
+		r.clients[name] = c
+		r.names = append(r.names, name)
+	}
+	sort.Strings(r.names)
+
+	s := routerService{router: r}
+	r.Domains = (*RouterDomainService)(&s)
+	r.Accounts = (*RouterAccountService)(&s)
+	r.Aliases = (*RouterAliasService)(&s)
+	r.OutputBccs = &RouterBccService{router: r, bccType: OutgoingBccType}
+	r.InputBccs = &RouterBccService{router: r, bccType: IncomingBccType}
+	return r
+}
+
+// Servers returns the sorted names of the servers.
+func (r *Router) Servers() []string {
+	return append([]string(nil), r.names...)
+}
+
+// Route maps the domain to the server. Static routes take precedence over discovered
+// ones.
+func (r *Router) Route(domain, server string) error {
+	if _, ok := r.clients[server]; !ok {
+		return fmt.Errorf("unknown server %q", server)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.static[normalizeAddress(domain)] = server
+	return nil
+}
+
+// Discover lists the domains of each server and replaces the discovered routes. Domains
+// found on several servers are reported in the returned error and not mapped, unless they
+// have a static route. If a server can't be listed, the routes are left unchanged.
+func (r *Router) Discover() error {
+	found := make(map[string][]string)
+	for _, name := range r.names {
+		domains, err := r.clients[name].Domains.List()
+		if err != nil {
+			r.mu.Lock()
+			r.discoveredAt = time.Now()
+			r.mu.Unlock()
+			return fmt.Errorf("list domains of %s: %v", name, err)
+		}
+		for _, d := range domains {
+			n := normalizeAddress(d.Name)
+			found[n] = append(found[n], name)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.discovered = make(map[string]string)
+	r.discoveredAt = time.Now()
+	var conflicts []string
+	for domain, servers := range found {
+		if len(servers) == 1 {
+			r.discovered[domain] = servers[0]
+		} else if _, ok := r.static[domain]; !ok {
+			conflicts = append(conflicts, fmt.Sprintf("%s (%s)", domain, strings.Join(servers, ", ")))
+		}
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return fmt.Errorf("domains found on several servers: %s", strings.Join(conflicts, "; "))
+	}
+	return nil
+}
+
+// Server returns the name of the server of the domain.
+func (r *Router) Server(domain string) (string, error) {
+	domain = normalizeAddress(domain)
+	if server, ok := r.route(domain); ok {
+		return server, nil
+	}
+
+	r.mu.Lock()
+	interval := r.DiscoveryInterval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	rediscover := !r.discoveredAt.IsZero() && time.Since(r.discoveredAt) >= interval
+	r.mu.Unlock()
+	if rediscover {
+		// Conflicts don't prevent routing the other domains.
+		r.Discover()
+		if server, ok := r.route(domain); ok {
+			return server, nil
+		}
+	}
+	return "", ErrNoRoute
+}
+
+// Client returns the client of the server of the domain.
+func (r *Router) Client(domain string) (*Client, error) {
+	server, err := r.Server(domain)
+	if err != nil {
+		return nil, err
+	}
+	return r.clients[server], nil
+}
+
+func (r *Router) route(domain string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if server, ok := r.static[domain]; ok {
+		return server, true
+	}
+	server, ok := r.discovered[domain]
+	return server, ok
+}
+
+func (r *Router) setRoute(domain, server string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(server) > 0 {
+		r.discovered[normalizeAddress(domain)] = server
+	} else {
+		delete(r.discovered, normalizeAddress(domain))
+	}
+}
+
+// List returns the domains of all servers, in the order of the server names.
+func (s *RouterDomainService) List() ([]Domain, error) {
+	var domains []Domain
+	for _, name := range s.router.names {
+		list, err := s.router.clients[name].Domains.List()
+		if err != nil {
+			return nil, fmt.Errorf("list domains of %s: %v", name, err)
+		}
+		domains = append(domains, list...)
+	}
+	return domains, nil
+}
+
+// Get fetches the domain from its server.
+func (s *RouterDomainService) Get(domain string) (*Domain, error) {
+	c, err := s.router.Client(domain)
+	if err != nil {
+		return nil, err
+	}
+	return c.Domains.Get(domain)
+}
+
+// Create creates the domain on the server it is routed to or, without a route, on the
+// DefaultServer.
+func (s *RouterDomainService) Create(domain string) error {
+	r := s.router
+	server, ok := r.route(normalizeAddress(domain))
+	if !ok {
+		server = r.DefaultServer
+	}
+	c, ok := r.clients[server]
+	if !ok {
+		return ErrNoRoute
+	}
+	if err := c.Domains.Create(domain); err != nil {
+		return err
+	}
+	r.setRoute(domain, server)
+	return nil
+}
+
+// Update updates the domain on its server. A renamed domain keeps its server.
+func (s *RouterDomainService) Update(name string, updateRequest *DomainUpdateRequest) error {
+	r := s.router
+	server, err := r.Server(name)
+	if err != nil {
+		return err
+	}
+	if err := r.clients[server].Domains.Update(name, updateRequest); err != nil {
+		return err
+	}
+	if n := updateRequest.Name; len(n) > 0 && normalizeAddress(n) != normalizeAddress(name) {
+		r.setRoute(name, "")
+		r.setRoute(n, server)
+	}
+	return nil
+}
+
+// Delete deletes the domain from its server.
+func (s *RouterDomainService) Delete(name string) error {
+	c, err := s.router.Client(name)
+	if err != nil {
+		return err
+	}
+	if err := c.Domains.Delete(name); err != nil {
+		return err
+	}
+	s.router.setRoute(name, "")
+	return nil
+}
+
+// List returns the accounts of the domain.
+func (s *RouterAccountService) List(domain string) ([]Account, error) {
+	c, err := s.router.Client(domain)
+	if err != nil {
+		return nil, err
+	}
+	return c.Accounts.List(domain)
+}
+
+// Get fetches the account.
+func (s *RouterAccountService) Get(domain, username string) (*Account, error) {
+	c, err := s.router.Client(domain)
+	if err != nil {
+		return nil, err
+	}
+	return c.Accounts.Get(domain, username)
+}
+
+// Create creates the account.
+func (s *RouterAccountService) Create(domain, username, password string) error {
+	c, err := s.router.Client(domain)
+	if err != nil {
+		return err
+	}
+	return c.Accounts.Create(domain, username, password)
+}
+
+// Update updates the account.
+func (s *RouterAccountService) Update(domain, username string, updateRequest *AccountUpdateRequest) error {
+	c, err := s.router.Client(domain)
+	if err != nil {
+		return err
+	}
+	return c.Accounts.Update(domain, username, updateRequest)
+}
+
+// Delete deletes the account.
+func (s *RouterAccountService) Delete(domain, username string) error {
+	c, err := s.router.Client(domain)
+	if err != nil {
+		return err
+	}
+	return c.Accounts.Delete(domain, username)
+}
+
+// List returns the aliases of the domain.
+func (s *RouterAliasService) List(domain string) ([]Alias, error) {
+	c, err := s.router.Client(domain)
+	if err != nil {
+		return nil, err
+	}
+	return c.Aliases.List(domain)
+}
+
+// Get fetches the aliases with the given name.
+func (s *RouterAliasService) Get(domain, alias string) ([]Alias, error) {
+	c, err := s.router.Client(domain)
+	if err != nil {
+		return nil, err
+	}
+	return c.Aliases.Get(domain, alias)
+}
+
+// GetForEmail fetches the alias with the given name and target address.
+func (s *RouterAliasService) GetForEmail(domain, alias, email string) (*Alias, error) {
+	c, err := s.router.Client(domain)
+	if err != nil {
+		return nil, err
+	}
+	return c.Aliases.GetForEmail(domain, alias, email)
+}
+
+// Create creates the alias.
+func (s *RouterAliasService) Create(domain, alias, email string) error {
+	c, err := s.router.Client(domain)
+	if err != nil {
+		return err
+	}
+	return c.Aliases.Create(domain, alias, email)
+}
+
+// Update updates the alias.
+func (s *RouterAliasService) Update(domain, alias, email string, ur *AliasUpdateRequest) error {
+	c, err := s.router.Client(domain)
+	if err != nil {
+		return err
+	}
+	return c.Aliases.Update(domain, alias, email, ur)
+}
+
+// Delete deletes the alias.
+func (s *RouterAliasService) Delete(domain, alias, email string) error {
+	c, err := s.router.Client(domain)
+	if err != nil {
+		return err
+	}
+	return c.Aliases.Delete(domain, alias, email)
+}
+
+func (s *RouterBccService) client(domain string) (*Client, error) {
+	return s.router.Client(domain)
+}
+
+// Get fetches the BCC of the account.
+func (s *RouterBccService) Get(domain, account string) (*Bcc, error) {
+	c, err := s.client(domain)
+	if err != nil {
+		return nil, err
+	}
+	return c.bccService(s.bccType).Get(domain, account)
+}
+
+// Create creates the BCC of the account.
+func (s *RouterBccService) Create(domain, account, email string) error {
+	c, err := s.client(domain)
+	if err != nil {
+		return err
+	}
+	return c.bccService(s.bccType).Create(domain, account, email)
+}
+
+// Update updates the BCC of the account.
+func (s *RouterBccService) Update(domain, account string, ur *BccUpdateRequest) error {
+	c, err := s.client(domain)
+	if err != nil {
+		return err
+	}
+	return c.bccService(s.bccType).Update(domain, account, ur)
+}
+
+// Delete deletes the BCC of the account.
+func (s *RouterBccService) Delete(domain, account string) error {
+	c, err := s.client(domain)
+	if err != nil {
+		return err
+	}
+	return c.bccService(s.bccType).Delete(domain, account)
+}
+
+// List returns the BCCs of all accounts in the domain.
+func (s *RouterBccService) List(domain string) ([]AccountBcc, error) {
+	c, err := s.client(domain)
+	if err != nil {
+		return nil, err
+	}
+	return c.bccLister(s.bccType).List(domain)
+}
+
+// ListAll returns the BCCs of all servers, in the order of the server names.
+func (s *RouterBccService) ListAll() ([]AccountBcc, error) {
+	var list []AccountBcc
+	for _, name := range s.router.names {
+		bccs, err := s.router.clients[name].bccLister(s.bccType).ListAll()
+		if err != nil {
+			return nil, fmt.Errorf("list bccs of %s: %v", name, err)
+		}
+		list = append(list, bccs...)
+	}
+	return list, nil
+}
+
+// SetEnabled enables or disables all BCCs in the domain and returns the changed ones.
+func (s *RouterBccService) SetEnabled(domain string, enabled bool) ([]AccountBcc, error) {
+	c, err := s.client(domain)
+	if err != nil {
+		return nil, err
+	}
+	return c.bccLister(s.bccType).SetEnabled(domain, enabled)
+}
@@ -0,0 +1,137 @@
+package goprsc
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRouter(t *testing.T) {
+	fs1, c1, close1 := startFake()
+	defer close1()
+	fs2, c2, close2 := startFake()
+	defer close2()
+
+	fs1.addDomain("one.com", true)
+	fs1.addAccount("one.com", "john", true)
+	fs1.addBcc("one.com", "john", OutgoingBccType, "archive@one.com", true)
+	fs2.addDomain("two.com", true)
+	fs2.addAccount("two.com", "jane", true)
+	fs2.addBcc("two.com", "jane", OutgoingBccType, "archive@two.com", true)
+
+	r := NewRouter(map[string]*Client{"s1": c1, "s2": c2})
+	if got := r.Servers(); strings.Join(got, ",") != "s1,s2" {
+		t.Fatalf("unexpected servers: %v", got)
+	}
+
+	// Without routes, domains can't be dispatched.
+	if _, err := r.Accounts.List("one.com"); err != ErrNoRoute {
+		t.Fatalf("expected ErrNoRoute, got: %v", err)
+	}
+
+	if err := r.Discover(); err != nil {
+		t.Fatal(err)
+	}
+	if server, err := r.Server("ONE.com"); err != nil || server != "s1" {
+		t.Fatalf("expected one.com on s1, got: %s, %v", server, err)
+	}
+	accounts, err := r.Accounts.List("one.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 1 || accounts[0].Username != "john" {
+		t.Fatalf("unexpected accounts: %+v", accounts)
+	}
+	if err := r.Aliases.Create("two.com", "info", "jane@two.com"); err != nil {
+		t.Fatal(err)
+	}
+	if fs2.alias("two.com", "info", "jane@two.com") == nil {
+		t.Fatal("expected alias to be created on s2")
+	}
+
+	// List calls are merged.
+	domains, err := r.Domains.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(domains) != 2 || domains[0].Name != "one.com" || domains[1].Name != "two.com" {
+		t.Fatalf("unexpected domains: %+v", domains)
+	}
+	bccs, err := r.OutputBccs.ListAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bccs) != 2 || bccs[0].Bcc.Email != "archive@one.com" || bccs[1].Bcc.Email != "archive@two.com" {
+		t.Fatalf("unexpected bccs: %+v", bccs)
+	}
+
+	// New domains are created on the static route or the default server.
+	if err := r.Domains.Create("three.com"); err != ErrNoRoute {
+		t.Fatalf("expected ErrNoRoute, got: %v", err)
+	}
+	if err := r.Route("three.com", "s2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Route("four.com", "s3"); err == nil {
+		t.Fatal("expected error for unknown server")
+	}
+	r.DefaultServer = "s1"
+	for _, name := range []string{"three.com", "four.com"} {
+		if err := r.Domains.Create(name); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Accounts.Create(name, "admin", "Secret-password-123"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fs2.account("three.com", "admin") == nil || fs1.account("four.com", "admin") == nil {
+		t.Fatal("expected domains to be created on the routed servers")
+	}
+
+	// Renamed domains keep their server, deleted ones lose their route.
+	if err := r.Domains.Update("four.com", &DomainUpdateRequest{Name: "five.com", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if server, err := r.Server("five.com"); err != nil || server != "s1" {
+		t.Fatalf("expected five.com on s1, got: %s, %v", server, err)
+	}
+	if err := r.Domains.Delete("one.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Domains.Get("one.com"); err != ErrNoRoute {
+		t.Fatalf("expected ErrNoRoute, got: %v", err)
+	}
+}
+
+func TestRouter_Rediscover(t *testing.T) {
+	fs1, c1, close1 := startFake()
+	defer close1()
+	fs2, c2, close2 := startFake()
+	defer close2()
+
+	r := NewRouter(map[string]*Client{"s1": c1, "s2": c2})
+	if err := r.Discover(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Domains created behind the back of the router are found by a new discovery.
+	fs2.addDomain("new.com", true)
+	r.DiscoveryInterval = time.Nanosecond
+	if _, err := r.Domains.Get("new.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Domains on several servers are conflicts, unless they have a static route.
+	fs1.addDomain("new.com", true)
+	err := r.Discover()
+	if err == nil || !strings.Contains(err.Error(), "new.com (s1, s2)") {
+		t.Fatalf("expected conflict, got: %v", err)
+	}
+	r.Route("new.com", "s1")
+	if err := r.Discover(); err != nil {
+		t.Fatal(err)
+	}
+	if server, _ := r.Server("new.com"); server != "s1" {
+		t.Fatalf("expected static route, got: %s", server)
+	}
+}
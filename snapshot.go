@@ -0,0 +1,124 @@
+package goprsc
+
+// Snapshot is the state of all domains, accounts, aliases and BCCs on a server.
+type Snapshot struct {
+	Domains []DomainSnapshot `json:"domains"`
+}
+
+// DomainSnapshot is the state of a domain together with its accounts and aliases.
+type DomainSnapshot struct {
+	Domain
+	Accounts []AccountSnapshot `json:"accounts"`
+	Aliases  []Alias           `json:"aliases"`
+}
+
+// AccountSnapshot is the state of an account together with its BCCs.
+type AccountSnapshot struct {
+	Account
+	IncomingBcc *Bcc `json:"incomingBcc,omitempty"`
+	OutgoingBcc *Bcc `json:"outgoingBcc,omitempty"`
+}
+
+// Snapshot reads all domains, accounts, aliases and BCCs from the server.
+func (c *Client) Snapshot() (*Snapshot, error) {
+	domains, err := c.Domains.List()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Snapshot{Domains: make([]DomainSnapshot, 0, len(domains))}
+	for _, d := range domains {
+		ds, err := c.domainSnapshot(d)
+		if err != nil {
+			return nil, err
+		}
+		s.Domains = append(s.Domains, *ds)
+	}
+	return s, nil
+}
+
+func (c *Client) domainSnapshot(d Domain) (*DomainSnapshot, error) {
+	accounts, err := c.Accounts.List(d.Name)
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := c.Aliases.List(d.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	ds := &DomainSnapshot{
+		Domain:   d,
+		Accounts: make([]AccountSnapshot, 0, len(accounts)),
+		Aliases:  aliases,
+	}
+	bccs, err := c.listAccountBccs(d.Name, accounts, bccTypes...)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range accounts {
+		as := AccountSnapshot{Account: a}
+		for _, b := range bccs {
+			if b.Account != a.Username {
+				continue
+			}
+			bcc := b.Bcc
+			if b.Type == IncomingBccType {
+				as.IncomingBcc = &bcc
+			} else {
+				as.OutgoingBcc = &bcc
+			}
+		}
+		ds.Accounts = append(ds.Accounts, as)
+	}
+	return ds, nil
+}
+
+// Domain returns the snapshot of the domain with the given name or nil if there is none.
+func (s *Snapshot) Domain(name string) *DomainSnapshot {
+	for i := range s.Domains {
+		if s.Domains[i].Name == name {
+			return &s.Domains[i]
+		}
+	}
+	return nil
+}
+
+// Account returns the snapshot of the account with the given username or nil if there is none.
+func (ds *DomainSnapshot) Account(username string) *AccountSnapshot {
+	for i := range ds.Accounts {
+		if ds.Accounts[i].Username == username {
+			return &ds.Accounts[i]
+		}
+	}
+	return nil
+}
+
+// Bccs returns the BCCs of all accounts in the domain.
+func (ds *DomainSnapshot) Bccs() []AccountBcc {
+	var bccs []AccountBcc
+	for _, a := range ds.Accounts {
+		if a.IncomingBcc != nil {
+			bccs = append(bccs, AccountBcc{Domain: ds.Name, Account: a.Username, Type: IncomingBccType, Bcc: *a.IncomingBcc})
+		}
+		if a.OutgoingBcc != nil {
+			bccs = append(bccs, AccountBcc{Domain: ds.Name, Account: a.Username, Type: OutgoingBccType, Bcc: *a.OutgoingBcc})
+		}
+	}
+	return bccs
+}
+
+// AliasGraph builds the alias graph of all domains in the snapshot.
+func (s *Snapshot) AliasGraph() *AliasGraph {
+	g := NewAliasGraph()
+	for _, d := range s.Domains {
+		g.AddDomain(d.Name)
+		for _, a := range d.Accounts {
+			g.AddAccount(d.Name, a.Account)
+		}
+		for _, a := range d.Aliases {
+			g.AddAlias(d.Name, a)
+		}
+	}
+	return g
+}
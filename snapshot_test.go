@@ -0,0 +1,31 @@
+package goprsc
+
+import "testing"
+
+func TestClient_Snapshot(t *testing.T) {
+	fs := setupFake()
+	defer shutdown()
+
+	fs.addDomain("example.com", true)
+	fs.addAccount("example.com", "john", true)
+	fs.addAccount("example.com", "jane", false)
+	fs.addAlias("example.com", "team", "john@example.com", true)
+	fs.addBcc("example.com", "john", OutgoingBccType, "archive@example.com", true)
+
+	s, err := client.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := s.Domain("example.com")
+	if d == nil || len(d.Accounts) != 2 || len(d.Aliases) != 1 {
+		t.Fatalf("unexpected domain snapshot: %#v", d)
+	}
+	john := d.Account("john")
+	if john == nil || john.IncomingBcc != nil || john.OutgoingBcc == nil || john.OutgoingBcc.Email != "archive@example.com" {
+		t.Fatalf("unexpected account snapshot: %#v", john)
+	}
+	if bccs := d.Bccs(); len(bccs) != 1 || bccs[0].Type != OutgoingBccType {
+		t.Fatalf("unexpected bccs: %#v", bccs)
+	}
+}
@@ -0,0 +1,233 @@
+package goprsc
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultRoleAddresses are the role addresses required by RFC 2142 for domains providing
+// mail and DNS service.
+var DefaultRoleAddresses = []string{"postmaster", "abuse", "hostmaster"}
+
+// DomainTemplate describes the accounts, aliases and BCCs provisioned with a new domain.
+// All string fields may contain placeholders of the form {name}, which are replaced with
+// the variables passed to CreateFromTemplate. The {domain} placeholder is replaced with
+// the name of the new domain. Placeholders without a variable are an error.
+type DomainTemplate struct {
+	Accounts []TemplateAccount
+	Aliases  []TemplateAlias
+	Bccs     []TemplateBcc
+}
+
+// TemplateAccount is an account of a domain template.
+type TemplateAccount struct {
+	Username string
+
+	// Password is the password of the account. If empty, a password is generated with
+	// the password policy of the client or DefaultPasswordPolicy.
+	Password string
+}
+
+// TemplateAlias is an alias of a domain template.
+type TemplateAlias struct {
+	Name  string
+	Email string
+}
+
+// TemplateBcc is a BCC of an account of a domain template.
+type TemplateBcc struct {
+	Account string
+
+	// Type is either IncomingBccType or OutgoingBccType.
+	Type  string
+	Email string
+}
+
+// TemplateResult describes the objects provisioned from a domain template.
+type TemplateResult struct {
+	// Created lists the created objects in the order of creation.
+	Created []string
+
+	// Passwords maps the addresses of the created accounts to their passwords.
+	Passwords map[string]string
+}
+
+// RoleAddressTemplate returns a template with aliases forwarding the DefaultRoleAddresses
+// to email, which may contain placeholders.
+func RoleAddressTemplate(email string) *DomainTemplate {
+	t := &DomainTemplate{}
+	for _, role := range DefaultRoleAddresses {
+		t.Aliases = append(t.Aliases, TemplateAlias{Name: role, Email: email})
+	}
+	return t
+}
+
+// CreateFromTemplate creates the domain with all accounts, aliases and BCCs of the
+// template, replacing the placeholders with vars. If any object can't be created, the
+// objects created so far, including the domain, are deleted.
+func (s *DomainService) CreateFromTemplate(domain string, t *DomainTemplate, vars map[string]string) (*TemplateResult, error) {
+	expand, err := templateReplacer(domain, vars)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTemplatePlaceholders(t, vars); err != nil {
+		return nil, err
+	}
+	for _, b := range t.Bccs {
+		if b.Type != IncomingBccType && b.Type != OutgoingBccType {
+			return nil, fmt.Errorf("unknown bcc type %q", b.Type)
+		}
+	}
+	policy := s.client.passwordPolicy
+	if policy == nil {
+		policy = DefaultPasswordPolicy()
+	}
+
+	result := &TemplateResult{Passwords: make(map[string]string)}
+	var undo undoStack
+	fail := func(err error) (*TemplateResult, error) {
+		if rerr := undo.rollback(); rerr != nil {
+			return nil, fmt.Errorf("%v (rollback failed: %v)", err, rerr)
+		}
+		return nil, err
+	}
+	created := func(object string, rollback func() error) {
+		result.Created = append(result.Created, object)
+		undo.push(rollback)
+	}
+
+	if err := s.Create(domain); err != nil {
+		return nil, err
+	}
+	created("domain "+domain, func() error { return s.Delete(domain) })
+
+	for _, a := range t.Accounts {
+		username, password := expand(a.Username), expand(a.Password)
+		if len(password) == 0 {
+			if password, err = policy.Generate(domain, username); err != nil {
+				return fail(err)
+			}
+		}
+		if err := s.client.Accounts.Create(domain, username, password); err != nil {
+			return fail(fmt.Errorf("create account %s@%s: %v", username, domain, err))
+		}
+		address := username + "@" + domain
+		result.Passwords[address] = password
+		created("account "+address, func() error { return s.client.Accounts.Delete(domain, username) })
+	}
+
+	for _, a := range t.Aliases {
+		name, email := expand(a.Name), expand(a.Email)
+		if err := s.client.Aliases.Create(domain, name, email); err != nil {
+			return fail(fmt.Errorf("create alias %s@%s -> %s: %v", name, domain, email, err))
+		}
+		created(fmt.Sprintf("alias %s@%s -> %s", name, domain, email), func() error {
+			return s.client.Aliases.Delete(domain, name, email)
+		})
+	}
+
+	for _, b := range t.Bccs {
+		account, email := expand(b.Account), expand(b.Email)
+		bccs := s.client.bccService(b.Type)
+		if err := bccs.Create(domain, account, email); err != nil {
+			return fail(fmt.Errorf("create %s bcc of %s@%s: %v", b.Type, account, domain, err))
+		}
+		created(fmt.Sprintf("%s bcc %s@%s", b.Type, account, domain), func() error {
+			return bccs.Delete(domain, account)
+		})
+	}
+
+	return result, nil
+}
+
+// templateReplacer returns a function replacing the placeholders in a template string.
+func templateReplacer(domain string, vars map[string]string) (func(string) string, error) {
+	pairs := []string{"{domain}", domain}
+	for k, v := range vars {
+		if k == "domain" {
+			return nil, fmt.Errorf("template variable %q is reserved", k)
+		}
+		pairs = append(pairs, "{"+k+"}", v)
+	}
+	r := strings.NewReplacer(pairs...)
+	return r.Replace, nil
+}
+
+// templatePlaceholder matches the placeholders in template strings.
+var templatePlaceholder = regexp.MustCompile(`\{[^{}]*\}`)
+
+// checkTemplatePlaceholders returns an error if a string of t contains a placeholder
+// which is neither {domain} nor one of vars.
+func checkTemplatePlaceholders(t *DomainTemplate, vars map[string]string) error {
+	var values []string
+	for _, a := range t.Accounts {
+		values = append(values, a.Username, a.Password)
+	}
+	for _, a := range t.Aliases {
+		values = append(values, a.Name, a.Email)
+	}
+	for _, b := range t.Bccs {
+		values = append(values, b.Account, b.Email)
+	}
+	for _, v := range values {
+		for _, p := range templatePlaceholder.FindAllString(v, -1) {
+			name := p[1 : len(p)-1]
+			if _, ok := vars[name]; !ok && name != "domain" {
+				return fmt.Errorf("unknown template placeholder %s in %q", p, v)
+			}
+		}
+	}
+	return nil
+}
+
+// RoleAddressRule returns an audit rule reporting enabled domains in which any of the
+// given role addresses is neither an enabled account nor an enabled alias. It isn't one
+// of the DefaultAuditRules, so it must be added to the rules of an Auditor explicitly.
+func RoleAddressRule(roles ...string) AuditRule {
+	return NewAuditRule("missing-role-address", func(s *Snapshot) []AuditFinding {
+		var findings []AuditFinding
+		for i := range s.Domains {
+			d := &s.Domains[i]
+			if !d.Enabled {
+				continue
+			}
+			missing := MissingRoleAddresses(d, roles...)
+			if len(missing) == 0 {
+				continue
+			}
+			findings = append(findings, AuditFinding{
+				Severity: SeverityWarning,
+				Object:   "domain " + d.Name,
+				Message:  fmt.Sprintf("missing role addresses: %s", strings.Join(missing, ", ")),
+				Fix:      "create aliases for the role addresses, e.g. with RoleAddressTemplate",
+			})
+		}
+		return findings
+	})
+}
+
+// MissingRoleAddresses returns the sorted role names which are neither an enabled account
+// nor an enabled alias in the domain.
+func MissingRoleAddresses(d *DomainSnapshot, roles ...string) []string {
+	present := make(map[string]bool)
+	for _, a := range d.Accounts {
+		if a.Enabled {
+			present[normalizeAddress(a.Username)] = true
+		}
+	}
+	for _, a := range d.Aliases {
+		if a.Enabled {
+			present[normalizeAddress(a.Name)] = true
+		}
+	}
+	var missing []string
+	for _, role := range roles {
+		if !present[normalizeAddress(role)] {
+			missing = append(missing, role)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
@@ -0,0 +1,80 @@
+package goprsc
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDomainService_CreateFromTemplate(t *testing.T) {
+	fs := setupFake()
+	defer shutdown()
+	fs.addDomain("example.net", true)
+
+	tmpl := RoleAddressTemplate("admin@{domain}")
+	tmpl.Accounts = []TemplateAccount{{Username: "admin"}, {Username: "{owner}", Password: "Kx7#mPq2vLw9"}}
+	tmpl.Aliases = append(tmpl.Aliases, TemplateAlias{Name: "info", Email: "{owner}@{domain}"})
+	tmpl.Bccs = []TemplateBcc{{Account: "{owner}", Type: IncomingBccType, Email: "archive@example.net"}}
+
+	result, err := client.Domains.CreateFromTemplate("example.com", tmpl, map[string]string{"owner": "john"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Created) != 8 {
+		t.Fatalf("expected 8 created objects, got: %v", result.Created)
+	}
+	if fs.passwords["john@example.com"] != "Kx7#mPq2vLw9" || fs.passwords["admin@example.com"] != result.Passwords["admin@example.com"] {
+		t.Fatalf("unexpected passwords: %v", result.Passwords)
+	}
+	if fs.alias("example.com", "postmaster", "admin@example.com") == nil || fs.alias("example.com", "info", "john@example.com") == nil {
+		t.Fatalf("missing template aliases: %#v", fs.aliases["example.com"])
+	}
+	if fs.bccs[bccKey(fs.account("example.com", "john").ID, IncomingBccType)] == nil {
+		t.Fatal("missing template bcc")
+	}
+
+	s, err := client.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if missing := MissingRoleAddresses(s.Domain("example.com"), DefaultRoleAddresses...); len(missing) != 0 {
+		t.Errorf("unexpected missing role addresses: %v", missing)
+	}
+	r := (&Auditor{Rules: []AuditRule{RoleAddressRule(DefaultRoleAddresses...)}}).Run(s)
+	if len(r.Findings) != 1 || r.Findings[0].Object != "domain example.net" ||
+		!strings.Contains(r.Findings[0].Message, "abuse, hostmaster, postmaster") {
+		t.Fatalf("unexpected findings: %#v", r.Findings)
+	}
+}
+
+func TestDomainService_CreateFromTemplateRollback(t *testing.T) {
+	fs := setupFake()
+	defer shutdown()
+
+	tmpl := RoleAddressTemplate("admin@{domain}")
+	tmpl.Accounts = []TemplateAccount{{Username: "admin"}}
+	tmpl.Bccs = []TemplateBcc{{Account: "admin", Type: OutgoingBccType, Email: "sent@example.net"}}
+	fs.fail = func(r *http.Request) bool {
+		return r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/bccs/outgoing")
+	}
+
+	if _, err := client.Domains.CreateFromTemplate("example.com", tmpl, nil); err == nil {
+		t.Fatal("expected CreateFromTemplate to fail")
+	}
+	if fs.domain("example.com") != nil || len(fs.accounts["example.com"]) != 0 || len(fs.aliases["example.com"]) != 0 {
+		t.Fatal("expected all created objects to be rolled back")
+	}
+
+	if _, err := client.Domains.CreateFromTemplate("example.com", tmpl, map[string]string{"domain": "x"}); err == nil {
+		t.Fatal("expected reserved variable to be rejected")
+	}
+
+	fs.fail = nil
+	tmpl = &DomainTemplate{Aliases: []TemplateAlias{{Name: "{usernme}", Email: "admin@{domain}"}}}
+	if _, err := client.Domains.CreateFromTemplate("example.com", tmpl, map[string]string{"username": "john"}); err == nil || !strings.Contains(err.Error(), "{usernme}") {
+		t.Fatalf("expected unknown placeholder to be rejected, got: %v", err)
+	}
+	if fs.domain("example.com") != nil {
+		t.Fatal("expected nothing to be created for an unknown placeholder")
+	}
+}
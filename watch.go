@@ -0,0 +1,292 @@
+package goprsc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ChangeType is the type of a change detected by a Watcher.
+type ChangeType string
+
+// Types of changes detected by a Watcher.
+const (
+	ChangeCreated  ChangeType = "created"
+	ChangeUpdated  ChangeType = "updated"
+	ChangeEnabled  ChangeType = "enabled"
+	ChangeDisabled ChangeType = "disabled"
+	ChangeDeleted  ChangeType = "deleted"
+)
+
+// ObjectType is the type of an object on the server.
+type ObjectType string
+
+// Types of objects on the server.
+const (
+	ObjectDomain  ObjectType = "domain"
+	ObjectAccount ObjectType = "account"
+	ObjectAlias   ObjectType = "alias"
+	ObjectBcc     ObjectType = "bcc"
+)
+
+// ChangeEvent describes a change of an object on the server.
+type ChangeEvent struct {
+	Type   ChangeType `json:"type"`
+	Object ObjectType `json:"object"`
+
+	// Key identifies the object: the domain name for domains, the address for accounts,
+	// "alias -> email" for aliases and "type address" for BCCs (e.g. "incoming john@example.com").
+	Key string `json:"key"`
+
+	// Domain is the domain the object belongs to.
+	Domain string `json:"domain"`
+
+	// Time is the creation or update time of the object or the time the deletion was
+	// detected.
+	Time time.Time `json:"time"`
+
+	// One of the following is set to the current state of the object. None is set for
+	// deleted objects.
+	DomainValue  *Domain  `json:"domainValue,omitempty"`
+	AccountValue *Account `json:"accountValue,omitempty"`
+	AliasValue   *Alias   `json:"aliasValue,omitempty"`
+	BccValue     *Bcc     `json:"bccValue,omitempty"`
+}
+
+// watchedObject is the observed state of an object which is persisted between polls.
+type watchedObject struct {
+	Object  ObjectType `json:"object"`
+	Key     string     `json:"key"`
+	Domain  string     `json:"domain"`
+	ID      int        `json:"id"`
+	Enabled bool       `json:"enabled"`
+	Email   string     `json:"email,omitempty"`
+	Updated time.Time  `json:"updated"`
+	Created time.Time  `json:"created"`
+
+	event ChangeEvent
+}
+
+// Watcher detects changes on the server by periodically comparing the Created and Updated
+// times and the state of all objects with the last observed state.
+type Watcher struct {
+	// Client is the client used to poll the server.
+	Client *Client
+
+	// Interval is the time between two polls (defaults to one minute).
+	Interval time.Duration
+
+	// StateFile is the path of a file in which the last observed state is persisted, so
+	// that a restarted watcher neither replays nor misses changes.
+	StateFile string
+
+	// ErrorHandler, if set, is called with the errors from the periodic polls.
+	ErrorHandler func(err error)
+
+	mu    sync.Mutex
+	state map[string]watchedObject
+
+	// now returns the current time. It is replaced in tests.
+	now func() time.Time
+}
+
+// Run polls the server every Interval and sends the detected changes to events until ctx
+// is done. The state is saved after all changes of a poll are sent. The first poll of a
+// watcher without saved state establishes the initial state and reports no changes.
+// Run returns ctx.Err() when stopped.
+func (w *Watcher) Run(ctx context.Context, events chan<- ChangeEvent) error {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		changes, state, err := w.poll()
+		if err != nil {
+			if w.ErrorHandler != nil {
+				w.ErrorHandler(err)
+			}
+		} else {
+			for _, e := range changes {
+				select {
+				case events <- e:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			if err := w.commit(state); err != nil && w.ErrorHandler != nil {
+				w.ErrorHandler(err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll compares the server state with the last observed state, saves the new state and
+// returns the detected changes.
+func (w *Watcher) Poll() ([]ChangeEvent, error) {
+	changes, state, err := w.poll()
+	if err != nil {
+		return nil, err
+	}
+	return changes, w.commit(state)
+}
+
+func (w *Watcher) poll() ([]ChangeEvent, map[string]watchedObject, error) {
+	previous, err := w.loadState()
+	if err != nil {
+		return nil, nil, err
+	}
+	s, err := w.Client.Snapshot()
+	if err != nil {
+		return nil, nil, err
+	}
+	current := watchedObjects(s)
+	if previous == nil {
+		return nil, current, nil
+	}
+
+	var changes []ChangeEvent
+	for k, cur := range current {
+		prev, ok := previous[k]
+		e := cur.event
+		switch {
+		case !ok || prev.ID != cur.ID:
+			if ok {
+				changes = append(changes, deletedEvent(prev, w.time()))
+			}
+			e.Type, e.Time = ChangeCreated, cur.Created
+		case prev.Enabled != cur.Enabled && cur.Enabled:
+			e.Type, e.Time = ChangeEnabled, cur.Updated
+		case prev.Enabled != cur.Enabled:
+			e.Type, e.Time = ChangeDisabled, cur.Updated
+		case !prev.Updated.Equal(cur.Updated) || prev.Email != cur.Email:
+			e.Type, e.Time = ChangeUpdated, cur.Updated
+		default:
+			continue
+		}
+		changes = append(changes, e)
+	}
+	for k, prev := range previous {
+		if _, ok := current[k]; !ok {
+			changes = append(changes, deletedEvent(prev, w.time()))
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		if !changes[i].Time.Equal(changes[j].Time) {
+			return changes[i].Time.Before(changes[j].Time)
+		}
+		return changes[i].Key < changes[j].Key
+	})
+	return changes, current, nil
+}
+
+func deletedEvent(o watchedObject, t time.Time) ChangeEvent {
+	return ChangeEvent{Type: ChangeDeleted, Object: o.Object, Key: o.Key, Domain: o.Domain, Time: t}
+}
+
+// watchedObjects returns the observed state of all objects in the snapshot.
+func watchedObjects(s *Snapshot) map[string]watchedObject {
+	objects := make(map[string]watchedObject)
+	add := func(o watchedObject, setValue func(e *ChangeEvent)) {
+		o.event = ChangeEvent{Object: o.Object, Key: o.Key, Domain: o.Domain}
+		setValue(&o.event)
+		objects[string(o.Object)+" "+o.Key] = o
+	}
+
+	for _, d := range s.Domains {
+		d := d
+		add(watchedObject{Object: ObjectDomain, Key: normalizeAddress(d.Name), Domain: d.Name, ID: d.ID, Enabled: d.Enabled, Created: d.Created.Time, Updated: d.Updated.Time},
+			func(e *ChangeEvent) { e.DomainValue = &d.Domain })
+
+		for _, a := range d.Accounts {
+			a := a
+			add(watchedObject{Object: ObjectAccount, Key: normalizeAddress(a.Username + "@" + d.Name), Domain: d.Name, ID: a.ID, Enabled: a.Enabled, Created: a.Created.Time, Updated: a.Updated.Time},
+				func(e *ChangeEvent) { e.AccountValue = &a.Account })
+		}
+		for _, b := range d.Bccs() {
+			b := b
+			key := fmt.Sprintf("%s %s", b.Type, normalizeAddress(b.Account+"@"+d.Name))
+			add(watchedObject{Object: ObjectBcc, Key: key, Domain: d.Name, ID: b.Bcc.ID, Enabled: b.Bcc.Enabled, Email: b.Bcc.Email, Created: b.Bcc.Created.Time, Updated: b.Bcc.Updated.Time},
+				func(e *ChangeEvent) { e.BccValue = &b.Bcc })
+		}
+		for _, a := range d.Aliases {
+			a := a
+			key := fmt.Sprintf("%s -> %s", normalizeAddress(a.Name+"@"+d.Name), normalizeAddress(a.Email))
+			add(watchedObject{Object: ObjectAlias, Key: key, Domain: d.Name, ID: a.ID, Enabled: a.Enabled, Created: a.Created.Time, Updated: a.Updated.Time},
+				func(e *ChangeEvent) { e.AliasValue = &a })
+		}
+	}
+	return objects
+}
+
+// loadState returns the last observed state or nil if there is none.
+func (w *Watcher) loadState() (map[string]watchedObject, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.state != nil || len(w.StateFile) == 0 {
+		return w.state, nil
+	}
+
+	data, err := ioutil.ReadFile(w.StateFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var objects []watchedObject
+	if err := json.Unmarshal(data, &objects); err != nil {
+		return nil, fmt.Errorf("invalid watcher state %s: %v", w.StateFile, err)
+	}
+	w.state = make(map[string]watchedObject, len(objects))
+	for _, o := range objects {
+		w.state[string(o.Object)+" "+o.Key] = o
+	}
+	return w.state, nil
+}
+
+// commit replaces the last observed state and saves it to the state file.
+func (w *Watcher) commit(state map[string]watchedObject) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.state = state
+	if len(w.StateFile) == 0 {
+		return nil
+	}
+
+	objects := make([]watchedObject, 0, len(state))
+	for _, o := range state {
+		objects = append(objects, o)
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		if objects[i].Object != objects[j].Object {
+			return objects[i].Object < objects[j].Object
+		}
+		return objects[i].Key < objects[j].Key
+	})
+	data, err := json.Marshal(objects)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(w.StateFile, data, 0600)
+}
+
+func (w *Watcher) time() time.Time {
+	if w.now != nil {
+		return w.now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,117 @@
+package goprsc
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func changeKeys(changes []ChangeEvent) map[string]ChangeType {
+	keys := make(map[string]ChangeType)
+	for _, c := range changes {
+		keys[string(c.Object)+" "+c.Key] = c.Type
+	}
+	return keys
+}
+
+func TestWatcher_Poll(t *testing.T) {
+	fs := setupFake()
+	defer shutdown()
+
+	fs.addDomain("example.com", true)
+	fs.addAccount("example.com", "john", true)
+	fs.addAccount("example.com", "jane", true)
+	fs.addAlias("example.com", "team", "john@example.com", true)
+	fs.addBcc("example.com", "john", IncomingBccType, "archive@example.com", true)
+
+	dir, err := ioutil.TempDir("", "goprsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	stateFile := filepath.Join(dir, "state.json")
+
+	w := &Watcher{Client: client, StateFile: stateFile}
+	changes, err := w.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("expected no changes on first poll, got: %v", changes)
+	}
+
+	fs.clock = time.Date(2017, 2, 1, 0, 0, 0, 0, time.UTC)
+	fs.addAccount("example.com", "new", true)
+	fs.account("example.com", "john").Enabled = false
+	fs.account("example.com", "john").Updated = fs.now()
+	b := fs.bccs[bccKey(fs.account("example.com", "john").ID, IncomingBccType)]
+	b.Email, b.Updated = "backup@example.com", fs.now()
+	fs.aliases["example.com"] = nil
+
+	// A restarted watcher resumes from the state file.
+	w = &Watcher{Client: client, StateFile: stateFile}
+	changes, err = w.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]ChangeType{
+		"account new@example.com":                    ChangeCreated,
+		"account john@example.com":                   ChangeDisabled,
+		"bcc incoming john@example.com":              ChangeUpdated,
+		"alias team@example.com -> john@example.com": ChangeDeleted,
+	}
+	got := changeKeys(changes)
+	if len(got) != len(expected) {
+		t.Fatalf("expected: %v, got: %v", expected, got)
+	}
+	for k, v := range expected {
+		if got[k] != v {
+			t.Errorf("%s: expected: %v, got: %v", k, v, got[k])
+		}
+	}
+	for _, c := range changes {
+		if c.Key == "new@example.com" && (c.AccountValue == nil || c.AccountValue.Username != "new") {
+			t.Errorf("unexpected account value: %#v", c.AccountValue)
+		}
+	}
+
+	if changes, err = w.Poll(); err != nil || len(changes) != 0 {
+		t.Fatalf("expected no changes, got: %v, %v", changes, err)
+	}
+}
+
+func TestWatcher_Run(t *testing.T) {
+	fs := setupFake()
+	defer shutdown()
+	fs.addDomain("example.com", true)
+
+	w := &Watcher{Client: client, Interval: time.Millisecond}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan ChangeEvent)
+	done := make(chan error)
+	go func() { done <- w.Run(ctx, events) }()
+
+	for {
+		w.mu.Lock()
+		initialized := w.state != nil
+		w.mu.Unlock()
+		if initialized {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	fs.addDomain("example.org", true)
+
+	e := <-events
+	if e.Type != ChangeCreated || e.Object != ObjectDomain || e.Key != "example.org" {
+		t.Fatalf("unexpected event: %#v", e)
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("expected: %v, got: %v", context.Canceled, err)
+	}
+}
@@ -0,0 +1,543 @@
+package goprsc
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWebhookMaxAttempts = 5
+	defaultWebhookBackoff     = time.Second
+	defaultWebhookMaxBackoff  = time.Hour
+
+	// WebhookSignatureHeader is the header carrying the HMAC-SHA256 signature of the
+	// payload, formatted as "sha256=<hex digest>".
+	WebhookSignatureHeader = "X-Goprsc-Signature"
+
+	// WebhookEventHeader is the header carrying the change type of the payload.
+	WebhookEventHeader = "X-Goprsc-Event"
+
+	// WebhookDeliveryHeader is the header carrying the unique delivery ID.
+	WebhookDeliveryHeader = "X-Goprsc-Delivery"
+)
+
+// Sources of webhook events.
+const (
+	WebhookSourceClient  = "client"
+	WebhookSourceWatcher = "watcher"
+)
+
+// Webhook is an URL to which change events are delivered.
+type Webhook struct {
+	URL string
+
+	// Secret is the key used for signing the payloads.
+	Secret string
+
+	// Types and Objects restrict the delivered events. Empty slices match all events.
+	Types   []ChangeType
+	Objects []ObjectType
+}
+
+func (w *Webhook) matches(e ChangeEvent) bool {
+	return matchesChangeType(w.Types, e.Type) && matchesObjectType(w.Objects, e.Object)
+}
+
+func matchesChangeType(types []ChangeType, t ChangeType) bool {
+	for _, v := range types {
+		if v == t {
+			return true
+		}
+	}
+	return len(types) == 0
+}
+
+func matchesObjectType(types []ObjectType, t ObjectType) bool {
+	for _, v := range types {
+		if v == t {
+			return true
+		}
+	}
+	return len(types) == 0
+}
+
+// WebhookPayload is the JSON body delivered to webhooks.
+type WebhookPayload struct {
+	ID string `json:"id"`
+
+	// Source is WebhookSourceClient for mutations made by an observed client and
+	// WebhookSourceWatcher for changes detected by a Watcher.
+	Source string `json:"source"`
+
+	// Login is the login of the client user which made the change. It is empty for
+	// changes detected by a Watcher.
+	Login string `json:"login,omitempty"`
+
+	Event ChangeEvent `json:"event"`
+}
+
+// webhookDelivery is a pending delivery of a payload to a webhook.
+type webhookDelivery struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+
+	// Webhook is the index of the webhook in the Webhooks of the dispatcher. Webhooks may
+	// share an URL, so it is used for finding the signing secret.
+	Webhook int `json:"webhook"`
+
+	Payload     json.RawMessage `json:"payload"`
+	Attempts    int             `json:"attempts"`
+	NextAttempt time.Time       `json:"nextAttempt"`
+	LastError   string          `json:"lastError,omitempty"`
+}
+
+// WebhookDispatcher delivers change events to webhooks. Deliveries are retried with
+// exponential backoff and can be persisted in a queue directory so that they survive
+// restarts. Deliveries which fail permanently are appended to a dead-letter file.
+type WebhookDispatcher struct {
+	Webhooks []Webhook
+
+	// HTTPClient is the client used for deliveries (defaults to http.DefaultClient).
+	HTTPClient *http.Client
+
+	// QueueDir, if set, is the directory in which pending deliveries are persisted.
+	QueueDir string
+
+	// DeadLetterFile, if set, is the JSON lines file to which permanently failed
+	// deliveries are appended.
+	DeadLetterFile string
+
+	// MaxAttempts is the number of attempts after which a delivery fails permanently
+	// (defaults to 5). Deliveries rejected with a 4xx status other than 408 and 429
+	// fail permanently without retrying.
+	MaxAttempts int
+
+	// Backoff is the delay before the first retry. It is doubled for each further
+	// retry up to one hour (defaults to one second).
+	Backoff time.Duration
+
+	// ErrorHandler, if set, is called with delivery and queue errors.
+	ErrorHandler func(err error)
+
+	mu      sync.Mutex
+	loaded  bool
+	pending map[string]*webhookDelivery
+	wake    chan struct{}
+
+	// now returns the current time. It is replaced in tests.
+	now func() time.Time
+}
+
+// Observe registers the dispatcher as an observer of the mutations made by c. Successful
+// creations and deletions are reported as ChangeCreated and ChangeDeleted. Updates which
+// change the enabled state of an object are reported as ChangeEnabled or ChangeDisabled,
+// other updates as ChangeUpdated. An update request always carries the enabled state, so
+// it is compared with the state last seen by the observer. Updates of objects not seen
+// before are reported as ChangeDisabled if they disable the object.
+func (d *WebhookDispatcher) Observe(c *Client) {
+	var mu sync.Mutex
+	enabled := make(map[string]bool)
+	c.AddMutationObserver(func(m *Mutation) {
+		mu.Lock()
+		e, ok := mutationEvent(m, enabled)
+		mu.Unlock()
+		if !ok {
+			return
+		}
+		if err := d.enqueue(WebhookPayload{Source: WebhookSourceClient, Login: m.Login, Event: e}); err != nil {
+			d.error(err)
+		}
+	})
+}
+
+// mutationEvent returns the change event of m. The enabled states of the objects are
+// looked up and recorded in enabled, keyed by object type and key.
+func mutationEvent(m *Mutation, enabled map[string]bool) (ChangeEvent, bool) {
+	t, ok := m.Target()
+	if !ok || !m.Succeeded() {
+		return ChangeEvent{}, false
+	}
+
+	e := ChangeEvent{Object: t.Object, Key: t.Key(), Domain: t.Domain, Time: m.Time}
+	key := string(e.Object) + " " + e.Key
+	var body struct {
+		Enabled *bool `json:"enabled"`
+	}
+	json.Unmarshal(m.Body, &body)
+	switch m.Method {
+	case http.MethodPost:
+		e.Type = ChangeCreated
+		if body.Enabled != nil {
+			enabled[key] = *body.Enabled
+		}
+	case http.MethodDelete:
+		e.Type = ChangeDeleted
+		delete(enabled, key)
+	default:
+		e.Type = ChangeUpdated
+		if body.Enabled == nil {
+			break
+		}
+		prev, known := enabled[key]
+		if *body.Enabled && known && !prev {
+			e.Type = ChangeEnabled
+		} else if !*body.Enabled && (!known || prev) {
+			e.Type = ChangeDisabled
+		}
+		enabled[key] = *body.Enabled
+	}
+	return e, true
+}
+
+// Watch runs w and queues the changes it detects until ctx is done.
+func (d *WebhookDispatcher) Watch(ctx context.Context, w *Watcher) error {
+	events := make(chan ChangeEvent)
+	done := make(chan error, 1)
+	go func() { done <- w.Run(ctx, events) }()
+
+	for {
+		select {
+		case e := <-events:
+			if err := d.Enqueue(WebhookSourceWatcher, e); err != nil {
+				d.error(err)
+			}
+		case err := <-done:
+			return err
+		}
+	}
+}
+
+// Enqueue queues the delivery of e to all matching webhooks.
+func (d *WebhookDispatcher) Enqueue(source string, e ChangeEvent) error {
+	return d.enqueue(WebhookPayload{Source: source, Event: e})
+}
+
+func (d *WebhookDispatcher) enqueue(p WebhookPayload) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.load(); err != nil {
+		return err
+	}
+
+	for i, w := range d.Webhooks {
+		if !w.matches(p.Event) {
+			continue
+		}
+		id, err := randomID()
+		if err != nil {
+			return err
+		}
+		p.ID = id
+		payload, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		dl := &webhookDelivery{ID: id, URL: w.URL, Webhook: i, Payload: payload, NextAttempt: d.time()}
+		if err := d.save(dl); err != nil {
+			return err
+		}
+		d.pending[id] = dl
+	}
+
+	if d.wake != nil {
+		select {
+		case d.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Run delivers the queued payloads until ctx is done. It returns ctx.Err() when stopped.
+func (d *WebhookDispatcher) Run(ctx context.Context) error {
+	d.mu.Lock()
+	if d.wake == nil {
+		d.wake = make(chan struct{}, 1)
+	}
+	wake := d.wake
+	d.mu.Unlock()
+
+	for {
+		d.Flush()
+
+		delay := time.Minute
+		if next, ok := d.nextAttempt(); ok {
+			delay = next.Sub(d.time())
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// Pending returns the number of queued deliveries.
+func (d *WebhookDispatcher) Pending() (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.load(); err != nil {
+		return 0, err
+	}
+	return len(d.pending), nil
+}
+
+// Flush attempts all deliveries which are due. It must not be called concurrently with Run.
+func (d *WebhookDispatcher) Flush() {
+	d.mu.Lock()
+	if err := d.load(); err != nil {
+		d.mu.Unlock()
+		d.error(err)
+		return
+	}
+	var due []*webhookDelivery
+	now := d.time()
+	for _, dl := range d.pending {
+		if !dl.NextAttempt.After(now) {
+			due = append(due, dl)
+		}
+	}
+	d.mu.Unlock()
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextAttempt.Before(due[j].NextAttempt)
+	})
+	for _, dl := range due {
+		d.attempt(dl)
+	}
+}
+
+func (d *WebhookDispatcher) nextAttempt() (time.Time, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var next time.Time
+	for _, dl := range d.pending {
+		if next.IsZero() || dl.NextAttempt.Before(next) {
+			next = dl.NextAttempt
+		}
+	}
+	return next, !next.IsZero()
+}
+
+func (d *WebhookDispatcher) attempt(dl *webhookDelivery) {
+	permanent, err := d.deliver(dl)
+	for _, err := range d.complete(dl, permanent, err) {
+		d.error(err)
+	}
+}
+
+// complete updates the queue after a delivery attempt and returns the errors to report.
+func (d *WebhookDispatcher) complete(dl *webhookDelivery, permanent bool, err error) []error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var errs []error
+	if err == nil {
+		delete(d.pending, dl.ID)
+		if err := d.remove(dl); err != nil {
+			errs = append(errs, err)
+		}
+		return errs
+	}
+
+	dl.Attempts++
+	dl.LastError = err.Error()
+	maxAttempts := d.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultWebhookMaxAttempts
+	}
+	if permanent || dl.Attempts >= maxAttempts {
+		delete(d.pending, dl.ID)
+		errs = append(errs, fmt.Errorf("webhook delivery %s to %s failed permanently: %v", dl.ID, dl.URL, err))
+		if err := d.deadLetter(dl); err != nil {
+			errs = append(errs, err)
+		}
+		if err := d.remove(dl); err != nil {
+			errs = append(errs, err)
+		}
+		return errs
+	}
+
+	backoff := d.Backoff
+	if backoff <= 0 {
+		backoff = defaultWebhookBackoff
+	}
+	for i := 1; i < dl.Attempts && backoff < defaultWebhookMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > defaultWebhookMaxBackoff {
+		backoff = defaultWebhookMaxBackoff
+	}
+	dl.NextAttempt = d.time().Add(backoff)
+	if err := d.save(dl); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// deliver posts the payload to the webhook. It reports whether a failure is permanent.
+func (d *WebhookDispatcher) deliver(dl *webhookDelivery) (bool, error) {
+	if dl.Webhook < 0 || dl.Webhook >= len(d.Webhooks) || d.Webhooks[dl.Webhook].URL != dl.URL {
+		return true, fmt.Errorf("webhook %s is no longer configured", dl.URL)
+	}
+	secret := d.Webhooks[dl.Webhook].Secret
+	var event struct {
+		Event ChangeEvent `json:"event"`
+	}
+	json.Unmarshal(dl.Payload, &event)
+
+	req, err := http.NewRequest(http.MethodPost, dl.URL, bytes.NewReader(dl.Payload))
+	if err != nil {
+		return true, err
+	}
+	req.Header.Set("Content-Type", mediaType)
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(WebhookEventHeader, string(event.Event.Type))
+	req.Header.Set(WebhookDeliveryHeader, dl.ID)
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(secret, dl.Payload))
+
+	hc := d.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	ioutil.ReadAll(resp.Body)
+
+	if sc := resp.StatusCode; sc >= 200 && sc <= 299 {
+		return false, nil
+	} else if sc >= 400 && sc <= 499 && sc != http.StatusRequestTimeout && sc != http.StatusTooManyRequests {
+		return true, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return false, fmt.Errorf("unexpected status %s", resp.Status)
+}
+
+// SignWebhookPayload returns the value of the signature header for payload.
+func SignWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature reports whether signature is a valid signature of payload.
+func VerifyWebhookSignature(secret string, payload []byte, signature string) bool {
+	return hmac.Equal([]byte(SignWebhookPayload(secret, payload)), []byte(signature))
+}
+
+// load reads the persisted deliveries from the queue directory once.
+func (d *WebhookDispatcher) load() error {
+	if d.loaded {
+		return nil
+	}
+	d.pending = make(map[string]*webhookDelivery)
+	d.loaded = true
+	if len(d.QueueDir) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(d.QueueDir, 0700); err != nil {
+		return err
+	}
+	entries, err := ioutil.ReadDir(d.QueueDir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(d.QueueDir, e.Name()))
+		if err != nil {
+			return err
+		}
+		dl := &webhookDelivery{}
+		if err := json.Unmarshal(data, dl); err != nil {
+			return fmt.Errorf("invalid queued delivery %s: %v", e.Name(), err)
+		}
+		d.pending[dl.ID] = dl
+	}
+	return nil
+}
+
+func (d *WebhookDispatcher) save(dl *webhookDelivery) error {
+	if len(d.QueueDir) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(dl)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(d.QueueDir, dl.ID+".json"), data, 0600)
+}
+
+func (d *WebhookDispatcher) remove(dl *webhookDelivery) error {
+	if len(d.QueueDir) == 0 {
+		return nil
+	}
+	if err := os.Remove(filepath.Join(d.QueueDir, dl.ID+".json")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (d *WebhookDispatcher) deadLetter(dl *webhookDelivery) error {
+	if len(d.DeadLetterFile) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(dl)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(d.DeadLetterFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (d *WebhookDispatcher) error(err error) {
+	if d.ErrorHandler != nil {
+		d.ErrorHandler(err)
+	}
+}
+
+func (d *WebhookDispatcher) time() time.Time {
+	if d.now != nil {
+		return d.now()
+	}
+	return time.Now()
+}
+
+// randomID returns a random 128 bit identifier encoded as hex.
+func randomID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
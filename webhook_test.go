@@ -0,0 +1,216 @@
+package goprsc
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type webhookReceiver struct {
+	mu       sync.Mutex
+	status   int
+	payloads []WebhookPayload
+	server   *httptest.Server
+}
+
+func newWebhookReceiver(t *testing.T, secret string) *webhookReceiver {
+	r := &webhookReceiver{status: http.StatusOK}
+	r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		if !VerifyWebhookSignature(secret, body, req.Header.Get(WebhookSignatureHeader)) {
+			t.Errorf("invalid signature: %s", req.Header.Get(WebhookSignatureHeader))
+		}
+		var p WebhookPayload
+		if err := json.Unmarshal(body, &p); err != nil {
+			t.Errorf("decode payload: %v", err)
+		}
+		if req.Header.Get(WebhookDeliveryHeader) != p.ID {
+			t.Errorf("expected delivery header: %s, got: %s", p.ID, req.Header.Get(WebhookDeliveryHeader))
+		}
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.payloads = append(r.payloads, p)
+		w.WriteHeader(r.status)
+	}))
+	return r
+}
+
+func TestWebhookDispatcher_Observe(t *testing.T) {
+	fs := setupFake()
+	defer shutdown()
+	fs.addDomain("example.com", true)
+
+	r := newWebhookReceiver(t, "secret")
+	defer r.server.Close()
+
+	d := &WebhookDispatcher{
+		Webhooks: []Webhook{{URL: r.server.URL, Secret: "secret", Objects: []ObjectType{ObjectAccount}}},
+	}
+	d.Observe(client)
+	client.Login = "admin"
+
+	if err := client.Accounts.Create("example.com", "john", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Accounts.Update("example.com", "john", &AccountUpdateRequest{Enabled: false}); err != nil {
+		t.Fatal(err)
+	}
+	// Changing the password of a disabled account sends enabled=false again, which
+	// must not be reported as disabling it.
+	pw := &AccountUpdateRequest{Password: "new-secret", ConfirmPassword: "new-secret", Enabled: false}
+	if err := client.Accounts.Update("example.com", "john", pw); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Accounts.Update("example.com", "john", &AccountUpdateRequest{Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Aliases.Create("example.com", "team", "john@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	d.Flush()
+
+	if len(r.payloads) != 4 {
+		t.Fatalf("expected 4 payloads, got: %#v", r.payloads)
+	}
+	testCases := []struct {
+		typ ChangeType
+		key string
+	}{
+		{ChangeCreated, "john@example.com"},
+		{ChangeDisabled, "john@example.com"},
+		{ChangeUpdated, "john@example.com"},
+		{ChangeEnabled, "john@example.com"},
+	}
+	for i, tc := range testCases {
+		p := r.payloads[i]
+		if p.Source != WebhookSourceClient || p.Login != "admin" || p.Event.Type != tc.typ || p.Event.Key != tc.key {
+			t.Errorf("unexpected payload: %#v", p)
+		}
+	}
+}
+
+func TestWebhookDispatcher_SharedURL(t *testing.T) {
+	secrets := map[ObjectType]string{ObjectDomain: "domain-secret", ObjectAccount: "account-secret"}
+	var mu sync.Mutex
+	var delivered int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		var p WebhookPayload
+		if err := json.Unmarshal(body, &p); err != nil {
+			t.Errorf("decode payload: %v", err)
+		}
+		if !VerifyWebhookSignature(secrets[p.Event.Object], body, req.Header.Get(WebhookSignatureHeader)) {
+			t.Errorf("invalid signature of %s event", p.Event.Object)
+		}
+		mu.Lock()
+		delivered++
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	d := &WebhookDispatcher{Webhooks: []Webhook{
+		{URL: server.URL, Secret: secrets[ObjectDomain], Objects: []ObjectType{ObjectDomain}},
+		{URL: server.URL, Secret: secrets[ObjectAccount], Objects: []ObjectType{ObjectAccount}},
+	}}
+	d.Enqueue(WebhookSourceWatcher, ChangeEvent{Type: ChangeCreated, Object: ObjectDomain, Key: "example.com"})
+	d.Enqueue(WebhookSourceWatcher, ChangeEvent{Type: ChangeCreated, Object: ObjectAccount, Key: "john@example.com"})
+	d.Flush()
+	if delivered != 2 {
+		t.Fatalf("expected 2 deliveries, got: %d", delivered)
+	}
+}
+
+func TestWebhookDispatcher_Retry(t *testing.T) {
+	r := newWebhookReceiver(t, "")
+	defer r.server.Close()
+	r.status = http.StatusServiceUnavailable
+
+	dir, err := ioutil.TempDir("", "goprsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2017, 1, 2, 0, 0, 0, 0, time.UTC)
+	var errs []error
+	d := &WebhookDispatcher{
+		Webhooks:       []Webhook{{URL: r.server.URL}},
+		QueueDir:       filepath.Join(dir, "queue"),
+		DeadLetterFile: filepath.Join(dir, "dead.jsonl"),
+		MaxAttempts:    3,
+		ErrorHandler:   func(err error) { errs = append(errs, err) },
+		now:            func() time.Time { return now },
+	}
+	if err := d.Enqueue(WebhookSourceWatcher, ChangeEvent{Type: ChangeCreated, Object: ObjectDomain, Key: "example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	d.Flush()
+	d.Flush()
+	if len(r.payloads) != 1 {
+		t.Fatalf("expected retry to wait for backoff, got %d attempts", len(r.payloads))
+	}
+
+	// A new dispatcher resumes from the queue directory.
+	d = &WebhookDispatcher{
+		Webhooks:       d.Webhooks,
+		QueueDir:       d.QueueDir,
+		DeadLetterFile: d.DeadLetterFile,
+		MaxAttempts:    d.MaxAttempts,
+		ErrorHandler:   d.ErrorHandler,
+		now:            d.now,
+	}
+	if n, err := d.Pending(); err != nil || n != 1 {
+		t.Fatalf("expected 1 pending delivery, got: %d, %v", n, err)
+	}
+
+	now = now.Add(time.Second)
+	d.Flush()
+	now = now.Add(2 * time.Second)
+	d.Flush()
+
+	if len(r.payloads) != 3 {
+		t.Fatalf("expected 3 attempts, got: %d", len(r.payloads))
+	}
+	if n, _ := d.Pending(); n != 0 {
+		t.Fatalf("expected no pending deliveries, got: %d", n)
+	}
+	data, err := ioutil.ReadFile(d.DeadLetterFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 1 || !strings.Contains(lines[0], `"attempts":3`) {
+		t.Fatalf("unexpected dead letters: %s", data)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got: %v", errs)
+	}
+	entries, _ := ioutil.ReadDir(d.QueueDir)
+	if len(entries) != 0 {
+		t.Fatalf("expected empty queue directory, got: %d entries", len(entries))
+	}
+}
+
+func TestWebhookDispatcher_PermanentFailure(t *testing.T) {
+	r := newWebhookReceiver(t, "")
+	defer r.server.Close()
+	r.status = http.StatusBadRequest
+
+	d := &WebhookDispatcher{Webhooks: []Webhook{{URL: r.server.URL}}}
+	if err := d.Enqueue(WebhookSourceWatcher, ChangeEvent{Type: ChangeDeleted, Object: ObjectDomain, Key: "example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	d.Flush()
+
+	if n, _ := d.Pending(); n != 0 || len(r.payloads) != 1 {
+		t.Fatalf("expected a single attempt, got: %d attempts, %d pending", len(r.payloads), n)
+	}
+}
@@ -0,0 +1,38 @@
+package goprsc
+
+// defaultWordlist is the list of words used for generated passphrases. Each word adds 8
+// bits of entropy.
+var defaultWordlist = []string{
+	"able", "acid", "aged", "also", "area", "army", "away", "baby",
+	"back", "ball", "band", "bank", "base", "bath", "bear", "beat",
+	"bell", "belt", "best", "bird", "blow", "blue", "boat", "body",
+	"bold", "bone", "book", "boot", "born", "boss", "both", "bowl",
+	"bulk", "burn", "bush", "busy", "cake", "call", "calm", "came",
+	"camp", "card", "care", "cart", "case", "cash", "cast", "cell",
+	"chef", "chip", "city", "clay", "club", "coal", "coat", "code",
+	"cold", "come", "cook", "cool", "cope", "copy", "core", "corn",
+	"cost", "crew", "crop", "dark", "data", "date", "dawn", "deal",
+	"dear", "deck", "deep", "deer", "desk", "dial", "diet", "disk",
+	"dock", "door", "dose", "down", "draw", "drop", "drum", "duck",
+	"dust", "duty", "earn", "east", "easy", "edge", "else", "even",
+	"ever", "exit", "face", "fact", "fair", "fall", "farm", "fast",
+	"fate", "fear", "feed", "feel", "file", "fill", "film", "find",
+	"fine", "fire", "firm", "fish", "five", "flag", "flat", "flow",
+	"folk", "food", "foot", "fork", "form", "fort", "four", "free",
+	"frog", "fuel", "full", "fund", "gain", "game", "gate", "gear",
+	"gift", "girl", "give", "glad", "goal", "gold", "golf", "good",
+	"gray", "grew", "grid", "grow", "gulf", "hair", "half", "hall",
+	"hand", "hang", "hard", "harm", "hawk", "head", "heat", "help",
+	"herb", "hero", "high", "hill", "hint", "hold", "hole", "home",
+	"hood", "hook", "hope", "horn", "host", "hour", "huge", "idea",
+	"inch", "iron", "item", "jazz", "join", "joke", "jump", "jury",
+	"keen", "keep", "kept", "kick", "kind", "king", "kite", "knee",
+	"knew", "knot", "lake", "lamp", "land", "lane", "last", "late",
+	"lawn", "lead", "leaf", "lean", "left", "lens", "life", "lift",
+	"lime", "line", "link", "lion", "list", "live", "load", "loan",
+	"lock", "loft", "long", "look", "loop", "lord", "love", "luck",
+	"lung", "made", "mail", "main", "make", "mall", "many", "mark",
+	"mask", "mass", "meal", "meat", "menu", "mild", "milk", "mill",
+	"mind", "mint", "miss", "mode", "moon", "more", "moss", "most",
+	"move", "much", "nail", "name", "navy", "near", "neat", "neck",
+}